@@ -0,0 +1,171 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette and fails on unknown requests
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests to the real API and appends them to the cassette
+	ModeRecord
+)
+
+// Interaction is a single recorded request and its response
+type Interaction struct {
+	Method       string          `json:"method"`
+	Path         string          `json:"path"`
+	RequestBody  json.RawMessage `json:"requestBody,omitempty"`
+	Status       int             `json:"status"`
+	Header       http.Header     `json:"header,omitempty"`
+	ResponseBody json.RawMessage `json:"responseBody,omitempty"`
+}
+
+// Cassette is a list of interactions stored as a JSON file
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is a http.RoundTripper that records interactions to a cassette file or replays them from it.
+// Requests are matched on method and path (including the query), so cassettes do not depend on the
+// host they were recorded against. Tokens and passwords are redacted before they are stored.
+type Recorder struct {
+	Path string
+	Mode Mode
+	// Base is used to reach the real API in ModeRecord
+	Base http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+// NewRecorder loads the cassette at path for ModeReplay, or starts an empty one for ModeRecord
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{Path: path, Mode: mode}
+	if mode == ModeReplay {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading cassette: %w", err)
+		}
+		if err := json.Unmarshal(b, &r.cassette); err != nil {
+			return nil, fmt.Errorf("parsing cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		reqBody = b
+	}
+	path := req.URL.RequestURI()
+
+	if r.Mode == ModeReplay {
+		return r.replay(req, path)
+	}
+
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(reqBody))
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	header := resp.Header.Clone()
+	header.Del("Set-Cookie")
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Method:       req.Method,
+		Path:         path,
+		RequestBody:  rawJSON(api.RedactJSON(reqBody)),
+		Status:       resp.StatusCode,
+		Header:       header,
+		ResponseBody: rawJSON(respBody),
+	})
+	r.mu.Unlock()
+
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, path string) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, in := range r.cassette.Interactions {
+		if r.used[i] || in.Method != req.Method || in.Path != path {
+			continue
+		}
+		r.used[i] = true
+		header := in.Header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(in.ResponseBody)),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("cassette %s has no unused interaction for %s %s", r.Path, req.Method, path)
+}
+
+// Save writes the recorded interactions to Path. It is a no-op in ModeReplay.
+func (r *Recorder) Save() error {
+	if r.Mode != ModeRecord {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, b, 0o644)
+}
+
+// rawJSON keeps valid JSON as-is and stores anything else as a JSON string
+func rawJSON(b []byte) json.RawMessage {
+	if len(b) == 0 {
+		return nil
+	}
+	if json.Valid(b) {
+		return b
+	}
+	s, _ := json.Marshal(string(b))
+	return s
+}
@@ -0,0 +1,101 @@
+package apitest
+
+import (
+	"context"
+	"github.com/edvin/oh/api"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// session is what TestRecorder asks the API, and what it got back
+type session struct {
+	server   api.CloudServer
+	attached []api.AttachedNetwork
+	missing  error
+}
+
+func runSession(t *testing.T, c *api.Client) session {
+	t.Helper()
+	ctx := context.Background()
+	var s session
+	var err error
+	if s.server, err = c.GetVirtualServer(ctx, 101); err != nil {
+		t.Fatalf("getting server 101: %v", err)
+	}
+	if _, err := c.AttachVirtualNetwork(ctx, 102, "net-a", "10.0.0.20", ""); err != nil {
+		t.Fatalf("attaching a network: %v", err)
+	}
+	if s.attached, err = c.ListAttachedVirtualNetworks(ctx, 102); err != nil {
+		t.Fatalf("listing attached networks: %v", err)
+	}
+	if _, s.missing = c.GetVirtualServer(ctx, 999); s.missing == nil {
+		t.Fatal("GET of a missing server succeeded")
+	}
+	return s
+}
+
+func recorderClient(baseURL string, rec *Recorder) *api.Client {
+	c := api.NewClient(baseURL, api.StaticToken(DefaultToken))
+	c.HTTPClient = &http.Client{Transport: rec}
+	return c
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	srv := httptest.NewServer(NewFake().Handler())
+	rec, err := NewRecorder(path, ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := runSession(t, recorderClient(srv.URL, rec))
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	srv.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), DefaultToken) {
+		t.Errorf("the cassette contains the token:\n%s", b)
+	}
+
+	// the fake is gone, and the host does not matter
+	rec, err = NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := recorderClient("http://replay.invalid/", rec)
+	replayed := runSession(t, c)
+	if !reflect.DeepEqual(replayed.server, recorded.server) || !reflect.DeepEqual(replayed.attached, recorded.attached) {
+		t.Errorf("replayed %+v, recorded %+v", replayed, recorded)
+	}
+	if replayed.missing.Error() != recorded.missing.Error() {
+		t.Errorf("replayed error %q, recorded %q", replayed.missing, recorded.missing)
+	}
+	if len(recorded.attached) != 1 || recorded.attached[0].IPv4 != "10.0.0.20" {
+		t.Errorf("recorded attachments %+v", recorded.attached)
+	}
+
+	// every interaction is replayed once, and unknown requests fail
+	for _, id := range []int{101, 102} {
+		_, err := c.GetVirtualServer(context.Background(), id)
+		if err == nil || !strings.Contains(err.Error(), "has no unused interaction for GET /servers/") {
+			t.Errorf("GET of server %d: got error %v", id, err)
+		}
+	}
+	if err := rec.Save(); err != nil {
+		t.Errorf("Save in replay mode: %v", err)
+	}
+
+	if _, err := NewRecorder(filepath.Join(t.TempDir(), "missing.json"), ModeReplay); err == nil {
+		t.Error("replaying a missing cassette succeeded")
+	}
+}
@@ -0,0 +1,429 @@
+// Package apitest provides an in-process fake of the oneHome API and a
+// cassette recorder, so the api package and the commands can be exercised
+// without a real account.
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultToken = "test-token"
+
+// RecordedRequest is a request received by the Fake
+type RecordedRequest struct {
+	Method string
+	Path   string
+	Body   []byte
+}
+
+// Fake is an in-memory implementation of the oneHome API endpoints used by package api.
+// Its exported fields may be changed before the server is started; use Lock/Unlock
+// to change them while requests are being served.
+type Fake struct {
+	Token    string
+	Customer api.Customer
+
+	Servers  map[int]*api.CloudServer
+	Images   []api.CloudServerImage
+	Products []api.Product
+	Flavours []api.CloudServerFlavour
+	Networks []api.VirtualNetwork
+	Attached map[int][]api.AttachedNetwork
+	Requests []RecordedRequest
+
+	mu     sync.Mutex
+	nextId int
+}
+
+// NewFake returns a Fake seeded with a few servers, images, products, flavours and networks
+func NewFake() *Fake {
+	released := api.Date{Timestamp: api.Timestamp{Time: time.Date(2024, 4, 25, 0, 0, 0, 0, time.UTC)}}
+	images := []api.CloudServerImage{
+		{Id: 1, Name: "Ubuntu 24.04", OSDistro: "ubuntu", OSVersion: "24.04", ReleaseDate: released, Size: 2361393152, VirtualSize: 3758096384, MinRAM: 1024, MinDisk: 10},
+		{Id: 2, Name: "Debian 12", OSDistro: "debian", OSVersion: "12", ReleaseDate: released, Size: 1073741824, VirtualSize: 2147483648, MinRAM: 512, MinDisk: 10},
+	}
+	return &Fake{
+		Token:    DefaultToken,
+		Customer: api.Customer{Id: 4711, Name: "Example GmbH"},
+		Servers: map[int]*api.CloudServer{
+			101: {Id: 101, ContractId: 5001, Name: "web-01", IPv4: "192.0.2.11", IPv6: "2001:db8::11", Status: "active", AvailabilityZone: "nbg1", Image: images[0]},
+			102: {Id: 102, ContractId: 5002, Name: "web-02", IPv4: "192.0.2.12", IPv6: "2001:db8::12", Status: "active", AvailabilityZone: "nbg1", Image: images[0]},
+			103: {Id: 103, ContractId: 5003, Name: "db-01", IPv4: "192.0.2.21", IPv6: "2001:db8::21", Status: "stopped", AvailabilityZone: "fra1", Image: images[1]},
+		},
+		Images: images,
+		Products: []api.Product{
+			{Id: 10, Name: "Cloud Server S", Plans: []api.ProductPlan{{Id: 100, Name: "Monthly", Price: 4.99}, {Id: 101, Name: "Yearly", Price: 49.90}}},
+			{Id: 11, Name: "Cloud Server M", Plans: []api.ProductPlan{{Id: 110, Name: "Monthly", Price: 9.99}}},
+		},
+		Flavours: []api.CloudServerFlavour{
+			{Id: 1, Name: "s1.small", Cores: 1, RamSize: 2048, StorageType: "ssd", StorageSize: 20},
+			{Id: 2, Name: "s1.medium", Cores: 2, RamSize: 4096, StorageType: "ssd", StorageSize: 40},
+		},
+		Networks: []api.VirtualNetwork{
+			{Id: "net-a", Name: "private", Subnets: []api.Subnet{{
+				Id: "sub-a", Name: "private-v4", IpVersion: 4, Cidr: "10.0.0.0/24",
+				AllocationPools: []api.AllocationPool{{Start: "10.0.0.10", End: "10.0.0.50"}},
+			}}},
+		},
+		Attached: map[int][]api.AttachedNetwork{},
+		nextId:   200,
+	}
+}
+
+func (f *Fake) Lock()   { f.mu.Lock() }
+func (f *Fake) Unlock() { f.mu.Unlock() }
+
+// NewServer starts an httptest.Server serving the Fake. The API base URL is the server URL plus "/".
+func NewServer(f *Fake) *httptest.Server {
+	return httptest.NewServer(f.Handler())
+}
+
+// Handler returns the http.Handler implementing the API
+func (f *Fake) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /customer", f.getCustomer)
+	mux.HandleFunc("GET /servers", f.listServers)
+	mux.HandleFunc("GET /servers/{id}", f.getServer)
+	mux.HandleFunc("POST /servers/order", f.orderServer)
+	mux.HandleFunc("POST /servers/{id}/{action}", f.executeAction)
+	mux.HandleFunc("GET /servers/{id}/possible-flavours", f.listFlavours)
+	mux.HandleFunc("POST /servers/{id}/change-flavour", f.changeFlavour)
+	mux.HandleFunc("GET /servers/{id}/networks", f.listAttached)
+	mux.HandleFunc("POST /servers/{id}/attach-network", f.attachNetwork)
+	mux.HandleFunc("POST /servers/{id}/detach-network", f.detachNetwork)
+	mux.HandleFunc("GET /images", f.listImages)
+	mux.HandleFunc("GET /images/{id}", f.getImage)
+	mux.HandleFunc("GET /products", f.listProducts)
+	mux.HandleFunc("GET /virtual-networks", f.listNetworks)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(strings.NewReader(string(body)))
+
+		f.mu.Lock()
+		f.Requests = append(f.Requests, RecordedRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+		token := f.Token
+		f.mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			writeError(w, http.StatusUnauthorized, "Unauthorized", "invalid or missing token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeData(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+func writeError(w http.ResponseWriter, status int, message, reason string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code":    status,
+		"message": message,
+		"details": map[string]any{"reason": reason},
+	})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request body", err.Error())
+		return false
+	}
+	return true
+}
+
+// server looks up the {id} path value, writing a 404 if it does not exist. The caller must hold f.mu.
+func (f *Fake) server(w http.ResponseWriter, r *http.Request) (*api.CloudServer, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "Not found", fmt.Sprintf("invalid server id %q", r.PathValue("id")))
+		return nil, false
+	}
+	s, ok := f.Servers[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Not found", fmt.Sprintf("server %d does not exist", id))
+		return nil, false
+	}
+	return s, true
+}
+
+func (f *Fake) getCustomer(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writeData(w, http.StatusOK, f.Customer)
+}
+
+func (f *Fake) listServers(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	servers := make([]api.CloudServer, 0, len(f.Servers))
+	for _, s := range f.Servers {
+		servers = append(servers, *s)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].Id < servers[j].Id })
+	writeData(w, http.StatusOK, servers)
+}
+
+func (f *Fake) getServer(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, ok := f.server(w, r); ok {
+		writeData(w, http.StatusOK, s)
+	}
+}
+
+func (f *Fake) executeAction(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.server(w, r)
+	if !ok {
+		return
+	}
+
+	action := api.VirtualServerAction(r.PathValue("action"))
+	switch action {
+	case api.VirtualServerSoftReboot, api.VirtualServerHardReboot, api.VirtualServerPowerOn:
+		s.Status = "active"
+	case api.VirtualServerPowerOff:
+		s.Status = "stopped"
+	case api.VirtualServerReset:
+		var req api.ResetCloudServerRequest
+		if !decode(w, r, &req) {
+			return
+		}
+		image, found := f.image(req.ImageId)
+		if !found {
+			writeError(w, http.StatusUnprocessableEntity, "Invalid request body", fmt.Sprintf("image %d does not exist", req.ImageId))
+			return
+		}
+		s.Image = image
+		s.Name = req.Name
+		s.Status = "active"
+	default:
+		writeError(w, http.StatusNotFound, "Not found", fmt.Sprintf("unknown action %q", action))
+		return
+	}
+
+	writeData(w, http.StatusOK, api.VirtualServerActionResponse{
+		Id:      s.Id,
+		Message: fmt.Sprintf("%s scheduled", action),
+	})
+}
+
+func (f *Fake) orderServer(w http.ResponseWriter, r *http.Request) {
+	var order api.CloudServerOrder
+	if !decode(w, r, &order) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	image, found := f.image(order.ImageId)
+	if !found {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request body", fmt.Sprintf("image %d does not exist", order.ImageId))
+		return
+	}
+
+	f.nextId++
+	id := f.nextId
+	f.Servers[id] = &api.CloudServer{
+		Id:               id,
+		ContractId:       9000 + id,
+		Name:             order.Name,
+		Status:           "active",
+		AvailabilityZone: order.AvailabilityZone,
+		Image:            image,
+	}
+	for _, n := range order.Networks {
+		f.Attached[id] = append(f.Attached[id], api.AttachedNetwork{
+			Id:   n.Network,
+			Name: f.networkName(n.Network),
+			IPv4: n.FixedIPv4,
+			IPv6: n.FixedIPv6,
+		})
+	}
+
+	writeData(w, http.StatusCreated, api.CloudServerOrderResponse{
+		Id:         id,
+		ContractId: 9000 + id,
+		OrderId:    fmt.Sprintf("ORD-%d", id),
+	})
+}
+
+func (f *Fake) listFlavours(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.server(w, r); ok {
+		writeData(w, http.StatusOK, f.Flavours)
+	}
+}
+
+func (f *Fake) changeFlavour(w http.ResponseWriter, r *http.Request) {
+	var req api.ChangeFlavourRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.server(w, r)
+	if !ok {
+		return
+	}
+	for _, fl := range f.Flavours {
+		if fl.Id == req.FlavourId {
+			writeData(w, http.StatusOK, api.ChangeFlavourResponse{
+				ServerId:  s.Id,
+				FlavourId: fl.Id,
+				Message:   fmt.Sprintf("flavour change to %s scheduled", fl.Name),
+			})
+			return
+		}
+	}
+	writeError(w, http.StatusUnprocessableEntity, "Invalid request body", fmt.Sprintf("flavour %d is not possible for server %d", req.FlavourId, s.Id))
+}
+
+func (f *Fake) listAttached(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, ok := f.server(w, r); ok {
+		networks := f.Attached[s.Id]
+		if networks == nil {
+			networks = []api.AttachedNetwork{}
+		}
+		writeData(w, http.StatusOK, networks)
+	}
+}
+
+func (f *Fake) attachNetwork(w http.ResponseWriter, r *http.Request) {
+	var req api.AttachVirtualNetworkRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.server(w, r)
+	if !ok {
+		return
+	}
+	name := f.networkName(req.NetworkId)
+	if name == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Invalid request body", fmt.Sprintf("network %q does not exist", req.NetworkId))
+		return
+	}
+	for _, n := range f.Attached[s.Id] {
+		if n.Id == req.NetworkId {
+			writeError(w, http.StatusConflict, "Conflict", fmt.Sprintf("network %q is already attached", req.NetworkId))
+			return
+		}
+	}
+	f.Attached[s.Id] = append(f.Attached[s.Id], api.AttachedNetwork{Id: req.NetworkId, Name: name, IPv4: req.IPv4, IPv6: req.IPv6})
+
+	writeData(w, http.StatusOK, api.AttachVirtualNetworkResponse{
+		ServerId:  s.Id,
+		NetworkId: req.NetworkId,
+		Message:   "network attached",
+	})
+}
+
+func (f *Fake) detachNetwork(w http.ResponseWriter, r *http.Request) {
+	var req api.DetachVirtualNetworkRequest
+	if !decode(w, r, &req) {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.server(w, r)
+	if !ok {
+		return
+	}
+	attached := f.Attached[s.Id]
+	for i, n := range attached {
+		if n.Id == req.NetworkId {
+			f.Attached[s.Id] = append(attached[:i:i], attached[i+1:]...)
+			writeData(w, http.StatusOK, api.DetachVirtualNetworkResponse{ServerId: s.Id, Message: "network detached"})
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not found", fmt.Sprintf("network %q is not attached to server %d", req.NetworkId, s.Id))
+}
+
+func (f *Fake) listImages(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writeData(w, http.StatusOK, f.Images)
+}
+
+func (f *Fake) getImage(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id, _ := strconv.Atoi(r.PathValue("id"))
+	if image, ok := f.image(id); ok {
+		writeData(w, http.StatusOK, image)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Not found", fmt.Sprintf("image %q does not exist", r.PathValue("id")))
+}
+
+func (f *Fake) listProducts(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writeData(w, http.StatusOK, f.Products)
+}
+
+func (f *Fake) listNetworks(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	writeData(w, http.StatusOK, f.Networks)
+}
+
+func (f *Fake) image(id int) (api.CloudServerImage, bool) {
+	for _, i := range f.Images {
+		if i.Id == id {
+			return i, true
+		}
+	}
+	return api.CloudServerImage{}, false
+}
+
+func (f *Fake) networkName(id string) string {
+	for _, n := range f.Networks {
+		if n.Id == id {
+			return n.Name
+		}
+	}
+	return ""
+}
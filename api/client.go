@@ -2,50 +2,152 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/edvin/oh/cache"
-	"github.com/spf13/viper"
 	"io"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
-// Fetch performs an HTTP request with the given method, URL, headers, body
-// The Bearer token from configuration is included into the Authorization header
+const (
+	DefaultUserAgent = "oh-cli"
+	DefaultTimeout   = 60 * time.Second
+)
+
+// TokenSource supplies the bearer token for each request
+type TokenSource interface {
+	Token() (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token
+type StaticToken string
+
+func (t StaticToken) Token() (string, error) {
+	if t == "" {
+		return "", fmt.Errorf("auth token is not set in the configuration")
+	}
+	return string(t), nil
+}
+
+// TokenFunc adapts a function to a TokenSource
+type TokenFunc func() (string, error)
+
+func (f TokenFunc) Token() (string, error) {
+	return f()
+}
+
+// CachedToken wraps a TokenSource so it is only asked once, for sources
+// that are slow or interactive such as keyrings and credential helpers
+func CachedToken(src TokenSource) TokenSource {
+	return &cachedToken{src: src}
+}
+
+type cachedToken struct {
+	src   TokenSource
+	once  sync.Once
+	token string
+	err   error
+}
+
+func (c *cachedToken) Token() (string, error) {
+	c.once.Do(func() {
+		c.token, c.err = c.src.Token()
+	})
+	return c.token, c.err
+}
+
+// Client talks to the oneHome API. It is safe for concurrent use, so several
+// clients with different base URLs and tokens can be used side by side.
+type Client struct {
+	BaseURL    string
+	Tokens     TokenSource
+	HTTPClient *http.Client
+	UserAgent  string
+	// DryRun, when set, receives a description of every request other than GET
+	// instead of the API, and Fetch returns ErrDryRun for those requests
+	DryRun io.Writer
+}
+
+// NewClient returns a Client with a default HTTP client and user agent
+func NewClient(baseURL string, tokens TokenSource) *Client {
+	return &Client{
+		BaseURL:    baseURL,
+		Tokens:     tokens,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		UserAgent:  DefaultUserAgent,
+	}
+}
+
+// URL resolves a path relative to the configured base URL
+func (c *Client) URL(relativePath string) (string, error) {
+	if c.BaseURL == "" {
+		return "", fmt.Errorf("base_url is not set in the configuration")
+	}
+	base := c.BaseURL
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return base + strings.TrimPrefix(relativePath, "/"), nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// Fetch performs an HTTP request with the given method, path and body using the client.
+// The Bearer token from the client's TokenSource is included into the Authorization header
 func Fetch[T any](
+	ctx context.Context,
+	c *Client,
 	method, relativePath string,
 	body any,
 	cacheKey cache.CacheKey,
 ) (T, error) {
 	var zero T
 
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"Accept":       "application/json",
+	if c.Tokens == nil {
+		return zero, fmt.Errorf("auth token is not set in the configuration")
+	}
+	token, err := c.Tokens.Token()
+	if err != nil {
+		return zero, err
 	}
 
-	token := viper.GetString("token")
-	if token == "" {
-		return zero, fmt.Errorf("auth token is not set in the configuration")
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"Accept":        "application/json",
+		"Authorization": "Bearer " + token,
+	}
+	if c.UserAgent != "" {
+		headers["User-Agent"] = c.UserAgent
 	}
-	headers["Authorization"] = "Bearer " + token
 
-	var reqBody io.Reader
+	var payload []byte
 	if body != nil {
-		b, err := json.Marshal(body)
+		payload, err = json.Marshal(body)
 		if err != nil {
 			return zero, fmt.Errorf("error marshalling request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(b)
 	}
 
-	baseURL := viper.GetString("base_url")
-	if baseURL == "" {
-		return zero, fmt.Errorf("base_url is not set in the configuration")
+	url, err := c.URL(relativePath)
+	if err != nil {
+		return zero, err
 	}
-	url := baseURL + relativePath
 
-	req, err := http.NewRequest(method, url, reqBody)
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return zero, fmt.Errorf("error creating request: %w", err)
 	}
@@ -53,8 +155,15 @@ func Fetch[T any](
 		req.Header.Set(k, v)
 	}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// GET requests still run so lookups and validation keep working
+	if c.DryRun != nil && method != http.MethodGet {
+		if err := writeDryRun(c.DryRun, req, payload); err != nil {
+			return zero, err
+		}
+		return zero, ErrDryRun
+	}
+
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return zero, fmt.Errorf("%s %s request failed: %w", method, relativePath, err)
 	}
@@ -75,8 +184,27 @@ func Fetch[T any](
 		return zero, fmt.Errorf("failed to decode JSON: %w", err)
 	}
 
-	if viper.GetBool("no-cache") || cacheKey != cache.NoCache {
+	if cacheKey != cache.NoCache {
 		cache.Store(cacheKey, &wrapper.Data)
 	}
 	return wrapper.Data, nil
 }
+
+// Mutate performs a state-changing request with Fetch. Once it succeeds, the cache keys
+// it dirties are purged so list commands and shell completion don't serve stale data.
+// In dry-run mode nothing changed, so the cache is left alone.
+func Mutate[T any](
+	ctx context.Context,
+	c *Client,
+	method, relativePath string,
+	body any,
+	dirties ...cache.CacheKey,
+) (T, error) {
+	result, err := Fetch[T](ctx, c, method, relativePath, body, cache.NoCache)
+	if err != nil {
+		return result, err
+	}
+	// best-effort, a stale entry will expire on its own
+	_ = cache.Invalidate(dirties...)
+	return result, nil
+}
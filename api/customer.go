@@ -0,0 +1,22 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/edvin/oh/cache"
+	"net/http"
+)
+
+// GetCustomer returns the customer the token belongs to
+func (c *Client) GetCustomer(ctx context.Context) (Customer, error) {
+	return Fetch[Customer](ctx, c, "GET", "customer", nil, cache.NoCache)
+}
+
+// IsUnauthorized reports whether err is an API error caused by an invalid or expired token
+func IsUnauthorized(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusUnauthorized || apiErr.Code == http.StatusForbidden
+	}
+	return false
+}
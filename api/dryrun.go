@@ -0,0 +1,46 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrDryRun is returned by Fetch instead of sending a state-changing request
+// while the client is in dry-run mode
+var ErrDryRun = errors.New("dry run, request not sent")
+
+// writeDryRun prints the request that would have been sent: method, URL, headers
+// with the token redacted, and the body exactly as it would be sent but for its secrets
+func writeDryRun(out io.Writer, req *http.Request, payload []byte) error {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s\n", req.Method, req.URL)
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(req.Header[k], ", ")
+		if strings.EqualFold(k, "Authorization") {
+			v = "Bearer " + redacted
+		}
+		fmt.Fprintf(&b, "%s: %s\n", k, v)
+	}
+
+	if len(payload) > 0 {
+		b.WriteString("\n")
+		b.Write(RedactJSON(payload))
+		b.WriteString("\n")
+	}
+	// separates the requests of bulk commands
+	b.WriteString("\n")
+
+	_, err := out.Write(b.Bytes())
+	return err
+}
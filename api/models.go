@@ -162,14 +162,6 @@ type ProductPlan struct {
 	Price float64 `json:"price"`
 }
 
-func (p Product) PlansString() string {
-	b, err := json.Marshal(p.Plans)
-	if err != nil {
-		return fmt.Sprintf("Unable to marshal plans for product %d: %v", p.Id, err)
-	}
-	return string(b)
-}
-
 type Product struct {
 	Id    int           `json:"id"`
 	Name  string        `json:"name"`
@@ -186,6 +178,8 @@ type CloudServerOrder struct {
 	SshKey           string      `json:"sshKey"`
 	StorageSize      string      `json:"storageSize"`
 	Networks         []VMNetwork `json:"networks"`
+	// UserData is cloud-init user-data, only sent when set
+	UserData string `json:"userData,omitempty"`
 }
 
 type CloudServerOrderResponse struct {
@@ -277,6 +271,7 @@ type ResetCloudServerRequest struct {
 	ImageId  int    `json:"imageId"`
 	Name     string `json:"name"`
 	Password string `json:"password"`
+	UserData string `json:"userData,omitempty"`
 }
 
 // Backwards compatible workaround for API returning strings instead of numbers in some areas
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// RateLimiter blocks until the next request may be sent
+type RateLimiter interface {
+	Wait(ctx context.Context) error
+}
+
+// bucket is the state of a token bucket with the given rate (tokens per second) and burst
+type bucket struct {
+	Tokens float64 `json:"tokens"`
+	Last   int64   `json:"last"`
+}
+
+// take refills the bucket up to now and takes a token.
+// It returns how long to wait before trying again if the bucket is empty.
+func (b *bucket) take(now time.Time, rate float64, burst int) time.Duration {
+	if b.Last == 0 {
+		b.Tokens = float64(burst)
+	} else if elapsed := now.Sub(time.Unix(0, b.Last)).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(burst), b.Tokens+elapsed*rate)
+	}
+	b.Last = now.UnixNano()
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return 0
+	}
+	return time.Duration((1 - b.Tokens) / rate * float64(time.Second))
+}
+
+// TokenBucket is an in-process RateLimiter
+type TokenBucket struct {
+	Rate  float64
+	Burst int
+
+	mu    sync.Mutex
+	state bucket
+}
+
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return &TokenBucket{Rate: rate, Burst: max(burst, 1)}
+}
+
+func (tb *TokenBucket) Wait(ctx context.Context) error {
+	return waitFor(ctx, func() (time.Duration, error) {
+		tb.mu.Lock()
+		defer tb.mu.Unlock()
+		return tb.state.take(time.Now(), tb.Rate, tb.Burst), nil
+	})
+}
+
+// SharedTokenBucket is a RateLimiter whose state lives in a locked file,
+// so every process on the machine using the same path shares one budget.
+// If the file cannot be opened or locked it throttles just this process.
+type SharedTokenBucket struct {
+	Path  string
+	Rate  float64
+	Burst int
+	// Logf, if set, is told once that the file could not be used
+	Logf func(format string, args ...any)
+
+	mu    sync.Mutex
+	local *TokenBucket
+}
+
+func NewSharedTokenBucket(path string, rate float64, burst int) *SharedTokenBucket {
+	return &SharedTokenBucket{Path: path, Rate: rate, Burst: max(burst, 1)}
+}
+
+func (sb *SharedTokenBucket) Wait(ctx context.Context) error {
+	if local := sb.fallback(); local != nil {
+		return local.Wait(ctx)
+	}
+	err := waitFor(ctx, sb.take)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+
+	sb.mu.Lock()
+	if sb.local == nil {
+		if sb.Logf != nil {
+			sb.Logf("rate limit is not shared between processes: %v", err)
+		}
+		sb.local = NewTokenBucket(sb.Rate, sb.Burst)
+	}
+	sb.mu.Unlock()
+	return sb.fallback().Wait(ctx)
+}
+
+// fallback returns the in-process bucket used once the file failed, or nil
+func (sb *SharedTokenBucket) fallback() *TokenBucket {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.local
+}
+
+func (sb *SharedTokenBucket) take() (time.Duration, error) {
+	f, err := os.OpenFile(sb.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return 0, fmt.Errorf("opening rate limit file: %w", err)
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return 0, fmt.Errorf("locking rate limit file: %w", err)
+	}
+	defer unlockFile(f)
+
+	var state bucket
+	if b, err := io.ReadAll(f); err == nil && len(b) > 0 {
+		// A corrupt file simply starts a fresh bucket
+		_ = json.Unmarshal(b, &state)
+	}
+
+	wait := state.take(time.Now(), sb.Rate, sb.Burst)
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return 0, err
+	}
+	if err := f.Truncate(0); err != nil {
+		return 0, err
+	}
+	if _, err := f.WriteAt(b, 0); err != nil {
+		return 0, err
+	}
+	return wait, nil
+}
+
+// waitFor calls take until it grants a token, sleeping for the returned duration in between
+func waitFor(ctx context.Context, take func() (time.Duration, error)) error {
+	for {
+		wait, err := take()
+		if err != nil || wait == 0 {
+			return err
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// RateLimitTransport is a http.RoundTripper that waits for the Limiter before every request
+type RateLimitTransport struct {
+	Base    http.RoundTripper
+	Limiter RateLimiter
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.Limiter != nil {
+		if err := t.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
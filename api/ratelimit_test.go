@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBucketTake(t *testing.T) {
+	start := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	// two tokens a second, three at once
+	tests := []struct {
+		now  time.Time
+		wait time.Duration
+	}{
+		// the first use fills the bucket
+		{at(0), 0},
+		{at(0), 0},
+		{at(0), 0},
+		{at(0), 500 * time.Millisecond},
+		// a failed take still moves the clock, half a token has come in since
+		{at(250 * time.Millisecond), 250 * time.Millisecond},
+		{at(500 * time.Millisecond), 0},
+		{at(500 * time.Millisecond), 500 * time.Millisecond},
+		// an idle bucket refills only up to the burst
+		{at(time.Minute), 0},
+		{at(time.Minute), 0},
+		{at(time.Minute), 0},
+		{at(time.Minute), 500 * time.Millisecond},
+		// a clock going backwards adds nothing
+		{at(time.Second), 500 * time.Millisecond},
+	}
+	var b bucket
+	for i, tt := range tests {
+		if wait := b.take(tt.now, 2, 3); wait != tt.wait {
+			t.Errorf("take %d at %s = %s, want %s", i+1, tt.now.Sub(start), wait, tt.wait)
+		}
+	}
+}
+
+// exhausted reports whether the limiter makes the caller wait for longer than a moment
+func exhausted(t *testing.T, l RateLimiter) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := l.Wait(ctx)
+	if err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait: %v", err)
+	}
+	return err != nil
+}
+
+func TestSharedTokenBucket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ratelimit")
+	// one token an hour, two at once
+	rate := 1.0 / 3600
+	a, b := NewSharedTokenBucket(path, rate, 2), NewSharedTokenBucket(path, rate, 2)
+
+	if exhausted(t, a) || exhausted(t, b) {
+		t.Fatal("the first two requests had to wait")
+	}
+	if !exhausted(t, a) || !exhausted(t, b) {
+		t.Error("the buckets do not share the budget of their file")
+	}
+	if exhausted(t, NewSharedTokenBucket(filepath.Join(t.TempDir(), "ratelimit"), rate, 2)) {
+		t.Error("a bucket on another file shares the budget")
+	}
+
+	// a corrupt file starts a fresh bucket
+	if err := os.WriteFile(path, []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if exhausted(t, a) {
+		t.Error("a corrupt file was not replaced by a full bucket")
+	}
+	if state, err := os.ReadFile(path); err != nil || !strings.HasPrefix(string(state), `{"tokens":1,`) {
+		t.Errorf("file after the request: %s, %v", state, err)
+	}
+}
+
+func TestSharedTokenBucketFallback(t *testing.T) {
+	sb := NewSharedTokenBucket(filepath.Join(t.TempDir(), "missing", "ratelimit"), 1.0/3600, 2)
+	var logged []string
+	sb.Logf = func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+
+	if exhausted(t, sb) || exhausted(t, sb) {
+		t.Fatal("the first two requests had to wait")
+	}
+	if !exhausted(t, sb) {
+		t.Error("the fallback does not limit the process")
+	}
+	if len(logged) != 1 || !strings.HasPrefix(logged[0], "rate limit is not shared between processes: opening rate limit file:") {
+		t.Errorf("logged %q, want one line about the file", logged)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int
+	// BaseDelay is the backoff before the first retry, doubled for every further retry
+	BaseDelay time.Duration
+	// MaxDelay caps a single backoff
+	MaxDelay time.Duration
+	// MaxElapsed is the total time budget for all attempts, zero means no budget
+	MaxElapsed time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	MaxElapsed:  2 * time.Minute,
+}
+
+// RetryTransport is a http.RoundTripper that retries idempotent requests on
+// network errors and 5xx responses, and any request on 429 and 503 responses.
+// Backoff is exponential with full jitter, and Retry-After is honored.
+type RetryTransport struct {
+	Base   http.RoundTripper
+	Policy RetryPolicy
+	// Timeout is applied to each attempt separately, zero means no timeout
+	Timeout time.Duration
+	// Logf receives a line for every retry, it may be nil
+	Logf func(format string, args ...any)
+}
+
+func (t *RetryTransport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var deadline time.Time
+	if t.Policy.MaxElapsed > 0 {
+		deadline = time.Now().Add(t.Policy.MaxElapsed)
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.attempt(req, attempt)
+
+		if attempt >= t.Policy.MaxAttempts || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.Policy.backoff(attempt)
+		if resp != nil {
+			if after, ok := retryAfter(resp); ok {
+				wait = after
+			}
+		}
+		if !deadline.IsZero() && time.Now().Add(wait).After(deadline) {
+			// Out of budget, hand back what we have
+			return resp, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if t.Logf != nil {
+			t.Logf("retrying %s %s in %s (attempt %d/%d): %s",
+				req.Method, req.URL, wait.Round(time.Millisecond), attempt+1, t.Policy.MaxAttempts, reason)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt sends a copy of req with a fresh body and the per-attempt timeout
+func (t *RetryTransport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	ctx := req.Context()
+	cancel := context.CancelFunc(func() {})
+	if t.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+	}
+
+	r := req.Clone(ctx)
+	if attempt > 1 && req.Body != nil {
+		if req.GetBody == nil {
+			cancel()
+			return nil, fmt.Errorf("cannot retry %s %s: request body is not rewindable", req.Method, req.URL)
+		}
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	resp, err := t.base().RoundTrip(r)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// Keep the attempt context alive until the caller is done with the body
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	if err != nil {
+		return isIdempotent(req.Method)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return isIdempotent(req.Method)
+	}
+	return false
+}
+
+// backoff returns a jittered delay for the given (1-based) attempt
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	limit := p.BaseDelay << (attempt - 1)
+	if limit <= 0 || (p.MaxDelay > 0 && limit > p.MaxDelay) {
+		limit = p.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit) + 1
+}
+
+// retryAfter parses the Retry-After header, either delay-seconds or an HTTP date
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		wait := time.Until(at)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
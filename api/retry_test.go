@@ -0,0 +1,254 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc lets a function stand in for the transport below RetryTransport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// statusServer answers with the statuses in turn, repeating the last one, and
+// counts the requests per method
+type statusServer struct {
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	attempts map[string]int
+	bodies   []string
+}
+
+func newStatusServer(t *testing.T, header http.Header, statuses ...int) (*statusServer, string) {
+	t.Helper()
+	s := &statusServer{statuses: statuses, header: header, attempts: map[string]int{}}
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+	return s, srv.URL
+}
+
+func (s *statusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+	n := s.attempts[r.Method]
+	s.attempts[r.Method]++
+
+	status := s.statuses[min(n, len(s.statuses)-1)]
+	if status != http.StatusOK {
+		for k, v := range s.header {
+			w.Header()[k] = v
+		}
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, strconv.Itoa(status))
+}
+
+func (s *statusServer) count(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[method]
+}
+
+// fastRetries retries right away, so the tests do not sleep
+var fastRetries = RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+func send(t *testing.T, rt http.RoundTripper, method, url, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		method   string
+		statuses []int
+		attempts int
+		want     int
+	}{
+		{http.MethodGet, []int{500, 502, 200}, 3, 200},
+		{http.MethodGet, []int{504}, 4, 504},
+		{http.MethodGet, []int{429, 503, 200}, 3, 200},
+		{http.MethodGet, []int{404}, 1, 404},
+		{http.MethodHead, []int{503, 200}, 2, 200},
+		{http.MethodPost, []int{500}, 1, 500},
+		{http.MethodPost, []int{502}, 1, 502},
+		{http.MethodPost, []int{429, 200}, 2, 200},
+		{http.MethodPost, []int{503, 503, 201}, 3, 201},
+		{http.MethodPost, []int{503}, 4, 503},
+		{http.MethodPut, []int{504}, 1, 504},
+		{http.MethodDelete, []int{500}, 1, 500},
+		{http.MethodDelete, []int{429, 204}, 2, 204},
+	}
+	for _, tt := range tests {
+		srv, url := newStatusServer(t, nil, tt.statuses...)
+		var retries int
+		rt := &RetryTransport{Policy: fastRetries, Logf: func(string, ...any) { retries++ }}
+
+		resp := send(t, rt, tt.method, url, `{"name":"web-01"}`)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s answered with %v: got %d, want %d", tt.method, tt.statuses, resp.StatusCode, tt.want)
+		}
+		if got := srv.count(tt.method); got != tt.attempts {
+			t.Errorf("%s answered with %v: %d attempts, want %d", tt.method, tt.statuses, got, tt.attempts)
+		}
+		if retries != tt.attempts-1 {
+			t.Errorf("%s answered with %v: %d retries logged, want %d", tt.method, tt.statuses, retries, tt.attempts-1)
+		}
+		for i, body := range srv.bodies {
+			if body != `{"name":"web-01"}` {
+				t.Errorf("%s attempt %d sent body %q", tt.method, i+1, body)
+			}
+		}
+	}
+}
+
+func TestRetryTransportNetworkErrors(t *testing.T) {
+	tests := []struct {
+		method   string
+		attempts int
+	}{
+		{http.MethodGet, 4},
+		{http.MethodPost, 1},
+		{http.MethodDelete, 1},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		rt := &RetryTransport{Policy: fastRetries, Base: roundTripFunc(func(*http.Request) (*http.Response, error) {
+			attempts++
+			return nil, errors.New("connection refused")
+		})}
+		req, _ := http.NewRequest(tt.method, "http://api.example/servers", nil)
+		if _, err := rt.RoundTrip(req); err == nil || !strings.Contains(err.Error(), "connection refused") {
+			t.Errorf("%s: got error %v", tt.method, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%s: %d attempts, want %d", tt.method, attempts, tt.attempts)
+		}
+	}
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	// Retry-After replaces the backoff, which would take an hour here
+	slow := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	for _, after := range []string{"0", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)} {
+		srv, url := newStatusServer(t, http.Header{"Retry-After": {after}}, 429, 200)
+		resp := send(t, &RetryTransport{Policy: slow}, http.MethodPost, url, "")
+		if resp.StatusCode != 200 || srv.count(http.MethodPost) != 2 {
+			t.Errorf("Retry-After %s: got %d after %d attempts", after, resp.StatusCode, srv.count(http.MethodPost))
+		}
+	}
+
+	// and is waited for even when the backoff would be shorter
+	srv, url := newStatusServer(t, http.Header{"Retry-After": {"1"}}, 503, 200)
+	start := time.Now()
+	if resp := send(t, &RetryTransport{Policy: fastRetries}, http.MethodGet, url, ""); resp.StatusCode != 200 {
+		t.Errorf("Retry-After 1: got %d after %d attempts", resp.StatusCode, srv.count(http.MethodGet))
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After 1: retried after %s", elapsed)
+	}
+
+	// a wait beyond the budget hands back the response instead of sleeping
+	budget := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxElapsed: time.Second}
+	for _, after := range []string{"120", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)} {
+		srv, url := newStatusServer(t, http.Header{"Retry-After": {after}}, 503, 200)
+		start := time.Now()
+		resp := send(t, &RetryTransport{Policy: budget}, http.MethodGet, url, "")
+		if resp.StatusCode != 503 || srv.count(http.MethodGet) != 1 {
+			t.Errorf("Retry-After %s: got %d after %d attempts", after, resp.StatusCode, srv.count(http.MethodGet))
+		}
+		if body, _ := io.ReadAll(resp.Body); string(body) != "503" {
+			t.Errorf("Retry-After %s: body %q of the handed back response", after, body)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Retry-After %s: returned after %s", after, elapsed)
+		}
+	}
+
+	// so does a backoff beyond the budget
+	srv, url = newStatusServer(t, nil, 500, 200)
+	outOfBudget := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Hour, MaxElapsed: time.Second}
+	if resp := send(t, &RetryTransport{Policy: outOfBudget}, http.MethodGet, url, ""); resp.StatusCode != 500 || srv.count(http.MethodGet) != 1 {
+		t.Errorf("backoff beyond the budget: got %d after %d attempts", resp.StatusCode, srv.count(http.MethodGet))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		min    time.Duration
+		max    time.Duration
+		ok     bool
+	}{
+		{"", 0, 0, false},
+		{"0", 0, 0, true},
+		{"7", 7 * time.Second, 7 * time.Second, true},
+		{"-1", 0, 0, false},
+		{"soon", 0, 0, false},
+		// HTTP dates have whole seconds
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second, true},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0, true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		got, ok := retryAfter(resp)
+		if ok != tt.ok || got < tt.min || got > tt.max {
+			t.Errorf("retryAfter(%q) = %s, %t, want %s..%s, %t", tt.header, got, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	tests := []struct {
+		attempt int
+		limit   time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{70, time.Second},
+	}
+	for _, tt := range tests {
+		var longest time.Duration
+		for range 1000 {
+			d := p.backoff(tt.attempt)
+			if d <= 0 || d > tt.limit {
+				t.Fatalf("backoff(%d) = %s, want 0..%s", tt.attempt, d, tt.limit)
+			}
+			longest = max(longest, d)
+		}
+		// full jitter spreads the delays over the whole range
+		if longest < tt.limit/2 {
+			t.Errorf("backoff(%d) never exceeded %s in 1000 tries, limit %s", tt.attempt, longest, tt.limit)
+		}
+	}
+
+	if d := (RetryPolicy{}).backoff(1); d != 0 {
+		t.Errorf("backoff without delays = %s, want 0", d)
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+type TraceLevel int
+
+const (
+	TraceOff TraceLevel = iota
+	// TraceVerbose logs method, URL, status, latency and request ID headers
+	TraceVerbose
+	// TraceDebug additionally dumps headers and bodies, with secrets redacted
+	TraceDebug
+)
+
+const redacted = "<redacted>"
+
+// requestIdHeaders are echoed in verbose output to correlate calls with the API side
+var requestIdHeaders = []string{"X-Request-Id", "X-Correlation-Id", "X-Trace-Id", "Traceparent"}
+
+// redactedFields are JSON body fields that are never written to the trace, matched case-insensitively
+var redactedFields = map[string]struct{}{
+	"password": {},
+	"token":    {},
+}
+
+// TraceTransport is a http.RoundTripper that logs every request and response to Out
+type TraceTransport struct {
+	Base  http.RoundTripper
+	Level TraceLevel
+	Out   io.Writer
+}
+
+func (t *TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Level == TraceOff || t.Out == nil {
+		return base.RoundTrip(req)
+	}
+
+	fmt.Fprintf(t.Out, "--> %s %s\n", req.Method, req.URL)
+	if t.Level >= TraceDebug {
+		writeHeaders(t.Out, req.Header)
+		if req.Body != nil && req.GetBody != nil {
+			if body, err := req.GetBody(); err == nil {
+				b, _ := io.ReadAll(body)
+				body.Close()
+				writeBody(t.Out, b)
+			}
+		}
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(t.Out, "<-- %s %s failed after %s: %v\n", req.Method, req.URL, elapsed, err)
+		return nil, err
+	}
+
+	line := fmt.Sprintf("<-- %s %s %s (%s)", resp.Status, req.Method, req.URL, elapsed)
+	for _, h := range requestIdHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			line += fmt.Sprintf(" %s=%s", h, v)
+		}
+	}
+	fmt.Fprintln(t.Out, line)
+
+	if t.Level >= TraceDebug {
+		writeHeaders(t.Out, resp.Header)
+		b, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		writeBody(t.Out, b)
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	return resp, nil
+}
+
+func writeHeaders(out io.Writer, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := strings.Join(header[k], ", ")
+		if strings.EqualFold(k, "Authorization") {
+			v = redacted
+		}
+		fmt.Fprintf(out, "    %s: %s\n", k, v)
+	}
+}
+
+func writeBody(out io.Writer, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	b = RedactJSON(b)
+	// one line per body
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, b); err == nil {
+		b = compact.Bytes()
+	}
+	fmt.Fprintf(out, "    %s\n", b)
+}
+
+// RedactJSON replaces the values of secret fields such as passwords in a JSON document.
+// Everything else is kept byte for byte, in its order and formatting.
+// Input that is not JSON is returned as-is.
+func RedactJSON(b []byte) []byte {
+	if !json.Valid(b) {
+		return b
+	}
+
+	// containers holds whether each open container is an object, and whether it
+	// expects a key next
+	type container struct{ object, key bool }
+	var containers []container
+	valueDone := func() {
+		if n := len(containers); n > 0 && containers[n-1].object {
+			containers[n-1].key = true
+		}
+	}
+
+	var out bytes.Buffer
+	copied := 0
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		switch tok := tok.(type) {
+		case json.Delim:
+			if tok == '{' || tok == '[' {
+				containers = append(containers, container{object: tok == '{', key: tok == '{'})
+				continue
+			}
+			containers = containers[:len(containers)-1]
+			valueDone()
+		case string:
+			n := len(containers)
+			if n == 0 || !containers[n-1].key {
+				valueDone()
+				continue
+			}
+			containers[n-1].key = false
+			if _, ok := redactedFields[strings.ToLower(tok)]; !ok {
+				continue
+			}
+			// the value starts after the colon, and ends where skipping it ends
+			start := int(dec.InputOffset())
+			for start < len(b) && strings.ContainsRune(" \t\r\n:", rune(b[start])) {
+				start++
+			}
+			if err := skipValue(dec); err != nil {
+				return b
+			}
+			out.Write(b[copied:start])
+			out.WriteString(`"` + redacted + `"`)
+			copied = int(dec.InputOffset())
+			valueDone()
+		default:
+			valueDone()
+		}
+	}
+	out.Write(b[copied:])
+	return out.Bytes()
+}
+
+// skipValue reads the next value from dec, with everything nested in it
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if d, ok := tok.(json.Delim); ok {
+			if d == '{' || d == '[' {
+				depth++
+			} else {
+				depth--
+			}
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
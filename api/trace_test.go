@@ -0,0 +1,24 @@
+package api
+
+import "testing"
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"imageId":2,"name":"web-01","password":"s3cret"}`, `{"imageId":2,"name":"web-01","password":"<redacted>"}`},
+		{`{"zeta":1, "Password" : "s3cret", "alpha":2}`, `{"zeta":1, "Password" : "<redacted>", "alpha":2}`},
+		{"{\n  \"token\": {\"a\": [1, 2]},\n  \"b\": \"token\"\n}", "{\n  \"token\": \"<redacted>\",\n  \"b\": \"token\"\n}"},
+		{`[{"name":"password","x":{"password":null}},"token"]`, `[{"name":"password","x":{"password":"<redacted>"}},"token"]`},
+		{`{"a":{"b":[]},"token":"t","c":{"password":"p"}}`, `{"a":{"b":[]},"token":"<redacted>","c":{"password":"<redacted>"}}`},
+		{`{"name":"no secrets"}`, `{"name":"no secrets"}`},
+		{`not json, password=s3cret`, `not json, password=s3cret`},
+		{``, ``},
+	}
+	for _, tt := range tests {
+		if got := string(RedactJSON([]byte(tt.in))); got != tt.want {
+			t.Errorf("RedactJSON(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"fmt"
+	"github.com/edvin/oh/cloudinit"
+	"golang.org/x/crypto/ssh"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// OrderCatalog is the live data an order is checked against. A nil Networks skips
+// the checks of the networks, for subscriptions that cannot list them.
+type OrderCatalog struct {
+	Products []Product
+	Images   []CloudServerImage
+	Networks []VirtualNetwork
+}
+
+// ValidationError is a problem with one field of a request, addressed by its JSON path
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors collects every problem found in a request
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, v := range e {
+		lines[i] = "  " + v.Error()
+	}
+	return fmt.Sprintf("the order has %d problem(s):\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+func (e *ValidationErrors) add(path, format string, args ...any) {
+	*e = append(*e, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateOrder cross-checks an order against the catalog and returns every problem
+// found, or nil if the order looks fine
+func ValidateOrder(order CloudServerOrder, catalog OrderCatalog) ValidationErrors {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(order.Name) == "" {
+		errs.add("name", "is required")
+	}
+	if order.Password == "" {
+		errs.add("password", "is required")
+	}
+	if strings.TrimSpace(order.AvailabilityZone) == "" {
+		errs.add("availabilityZone", "is required")
+	}
+
+	validateProduct(order, catalog.Products, &errs)
+	image := validateImage(order, catalog.Images, &errs)
+	validateStorage(order, image, &errs)
+
+	if order.SshKey != "" {
+		if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(order.SshKey)); err != nil {
+			errs.add("sshKey", "is not an OpenSSH public key: %v", err)
+		}
+	}
+
+	if order.UserData != "" {
+		if err := cloudinit.Validate(order.UserData); err != nil {
+			errs.add("userData", "%v", err)
+		}
+	}
+
+	if catalog.Networks != nil {
+		validateNetworks(order.Networks, catalog.Networks, &errs)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func validateProduct(order CloudServerOrder, products []Product, errs *ValidationErrors) {
+	var product *Product
+	for i := range products {
+		if products[i].Id == order.ProductId {
+			product = &products[i]
+		}
+	}
+	if product == nil {
+		errs.add("productId", "product %d does not exist", order.ProductId)
+		return
+	}
+
+	for _, plan := range product.Plans {
+		if plan.Id == order.ProductPlanId {
+			return
+		}
+	}
+	for _, other := range products {
+		for _, plan := range other.Plans {
+			if plan.Id == order.ProductPlanId {
+				errs.add("productPlanId", "plan %d belongs to product %d (%s), not to %d (%s)",
+					plan.Id, other.Id, other.Name, product.Id, product.Name)
+				return
+			}
+		}
+	}
+	errs.add("productPlanId", "plan %d does not exist; product %d has plans %s",
+		order.ProductPlanId, product.Id, planIds(product.Plans))
+}
+
+func validateImage(order CloudServerOrder, images []CloudServerImage, errs *ValidationErrors) *CloudServerImage {
+	for i := range images {
+		if images[i].Id == order.ImageId {
+			return &images[i]
+		}
+	}
+	errs.add("imageId", "image %d does not exist", order.ImageId)
+	return nil
+}
+
+func validateStorage(order CloudServerOrder, image *CloudServerImage, errs *ValidationErrors) {
+	size, err := strconv.Atoi(strings.TrimSpace(order.StorageSize))
+	switch {
+	case order.StorageSize == "":
+		errs.add("storageSize", "is required")
+	case err != nil:
+		errs.add("storageSize", "%q is not a whole number of GB", order.StorageSize)
+	case size <= 0:
+		errs.add("storageSize", "must be positive, got %d", size)
+	case image != nil && size < image.MinDisk:
+		errs.add("storageSize", "image %d needs at least %d GB, got %d", image.Id, image.MinDisk, size)
+	}
+}
+
+func validateNetworks(requested []VMNetwork, networks []VirtualNetwork, errs *ValidationErrors) {
+	seen := map[string]bool{}
+	for i, r := range requested {
+		path := fmt.Sprintf("networks[%d]", i)
+
+		var network *VirtualNetwork
+		for j := range networks {
+			if networks[j].Id == r.Network {
+				network = &networks[j]
+			}
+		}
+		switch {
+		case r.Network == "":
+			errs.add(path+".network", "is required")
+			continue
+		case network == nil:
+			errs.add(path+".network", "network %q does not exist", r.Network)
+			continue
+		case seen[r.Network]:
+			errs.add(path+".network", "network %q is listed more than once", r.Network)
+		}
+		seen[r.Network] = true
+
+		if r.FixedIPv4 != "" {
+			validateFixedIP(path+".fixed_ipv4", r.FixedIPv4, 4, network, errs)
+		}
+		if r.FixedIPv6 != "" {
+			validateFixedIP(path+".fixed_ipv6", r.FixedIPv6, 6, network, errs)
+		}
+	}
+}
+
+// validateFixedIP checks that ip is an address of the version that lies in an allocation pool
+// of the network, or in the subnet when it has no pools
+func validateFixedIP(path, ip string, version int, network *VirtualNetwork, errs *ValidationErrors) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || (version == 4) != addr.Is4() {
+		errs.add(path, "%q is not an IPv%d address", ip, version)
+		return
+	}
+
+	var ranges []string
+	for _, subnet := range network.Subnets {
+		if subnet.IpVersion != version {
+			continue
+		}
+		if len(subnet.AllocationPools) == 0 {
+			if prefix, err := netip.ParsePrefix(subnet.Cidr); err == nil {
+				if prefix.Contains(addr) {
+					return
+				}
+				ranges = append(ranges, subnet.Cidr)
+			}
+			continue
+		}
+		for _, pool := range subnet.AllocationPools {
+			start, err1 := netip.ParseAddr(pool.Start)
+			end, err2 := netip.ParseAddr(pool.End)
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			if addr.Compare(start) >= 0 && addr.Compare(end) <= 0 {
+				return
+			}
+			ranges = append(ranges, pool.Start+"-"+pool.End)
+		}
+	}
+
+	if len(ranges) == 0 {
+		errs.add(path, "network %q has no IPv%d subnet", network.Id, version)
+		return
+	}
+	errs.add(path, "%s is outside the allocation pools of network %q (%s)", ip, network.Id, strings.Join(ranges, ", "))
+}
+
+func planIds(plans []ProductPlan) string {
+	ids := make([]string, len(plans))
+	for i, p := range plans {
+		ids[i] = strconv.Itoa(p.Id)
+	}
+	return "[" + strings.Join(ids, ", ") + "]"
+}
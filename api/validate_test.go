@@ -0,0 +1,126 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+)
+
+func testCatalog() OrderCatalog {
+	return OrderCatalog{
+		Products: []Product{
+			{Id: 10, Name: "VPS S", Plans: []ProductPlan{{Id: 1, Name: "Monthly"}, {Id: 2, Name: "Yearly"}}},
+			{Id: 11, Name: "VPS M", Plans: []ProductPlan{{Id: 3, Name: "Monthly"}}},
+		},
+		Images: []CloudServerImage{{Id: 1, Name: "Ubuntu", MinDisk: 10}},
+		Networks: []VirtualNetwork{{
+			Id: "net-a",
+			Subnets: []Subnet{
+				{IpVersion: 4, Cidr: "10.0.0.0/24", AllocationPools: []AllocationPool{{Start: "10.0.0.10", End: "10.0.0.99"}}},
+				{IpVersion: 6, Cidr: "fd00::/64"},
+			},
+		}},
+	}
+}
+
+func testSshKey(t *testing.T) string {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestValidateOrder(t *testing.T) {
+	valid := CloudServerOrder{
+		ProductId:        10,
+		ProductPlanId:    2,
+		ImageId:          1,
+		Password:         "s3cret",
+		AvailabilityZone: "nbg1",
+		Name:             "web-01",
+		SshKey:           testSshKey(t),
+		StorageSize:      "20",
+		Networks:         []VMNetwork{{Network: "net-a", FixedIPv4: "10.0.0.10", FixedIPv6: "fd00::5"}},
+	}
+
+	tests := []struct {
+		name   string
+		change func(o *CloudServerOrder, c *OrderCatalog)
+		want   []string // path: message fragment
+	}{
+		{"valid", func(o *CloudServerOrder, c *OrderCatalog) {}, nil},
+		{"without SSH key", func(o *CloudServerOrder, c *OrderCatalog) { o.SshKey = "" }, nil},
+		{"required fields", func(o *CloudServerOrder, c *OrderCatalog) {
+			o.Name, o.Password, o.AvailabilityZone = " ", "", ""
+		}, []string{"name: is required", "password: is required", "availabilityZone: is required"}},
+		{"unknown product", func(o *CloudServerOrder, c *OrderCatalog) { o.ProductId = 99 },
+			[]string{"productId: product 99 does not exist"}},
+		{"unknown plan", func(o *CloudServerOrder, c *OrderCatalog) { o.ProductPlanId = 9 },
+			[]string{"productPlanId: plan 9 does not exist; product 10 has plans [1, 2]"}},
+		{"plan of another product", func(o *CloudServerOrder, c *OrderCatalog) { o.ProductPlanId = 3 },
+			[]string{"productPlanId: plan 3 belongs to product 11 (VPS M)"}},
+		{"unknown image", func(o *CloudServerOrder, c *OrderCatalog) { o.ImageId = 7 },
+			[]string{"imageId: image 7 does not exist"}},
+		{"storage below the image minimum", func(o *CloudServerOrder, c *OrderCatalog) { o.StorageSize = "5" },
+			[]string{"storageSize: image 1 needs at least 10 GB, got 5"}},
+		{"storage not positive", func(o *CloudServerOrder, c *OrderCatalog) { o.StorageSize = "0" },
+			[]string{"storageSize: must be positive"}},
+		{"storage not a number", func(o *CloudServerOrder, c *OrderCatalog) { o.StorageSize = "20GB" },
+			[]string{`storageSize: "20GB" is not a whole number of GB`}},
+		{"storage missing", func(o *CloudServerOrder, c *OrderCatalog) { o.StorageSize = "" },
+			[]string{"storageSize: is required"}},
+		{"invalid SSH key", func(o *CloudServerOrder, c *OrderCatalog) { o.SshKey = "ssh-rsa nope" },
+			[]string{"sshKey: is not an OpenSSH public key"}},
+		{"invalid user-data", func(o *CloudServerOrder, c *OrderCatalog) { o.UserData = "#cloud-config\n: [" },
+			[]string{"userData: "}},
+		{"fixed IPv4 outside the pools", func(o *CloudServerOrder, c *OrderCatalog) { o.Networks[0].FixedIPv4 = "10.0.0.200" },
+			[]string{`networks[0].fixed_ipv4: 10.0.0.200 is outside the allocation pools of network "net-a" (10.0.0.10-10.0.0.99)`}},
+		{"fixed IPv6 outside the subnet", func(o *CloudServerOrder, c *OrderCatalog) { o.Networks[0].FixedIPv6 = "fd01::5" },
+			[]string{`networks[0].fixed_ipv6: fd01::5 is outside the allocation pools of network "net-a" (fd00::/64)`}},
+		{"fixed IP of the wrong version", func(o *CloudServerOrder, c *OrderCatalog) { o.Networks[0].FixedIPv4 = "fd00::5" },
+			[]string{`networks[0].fixed_ipv4: "fd00::5" is not an IPv4 address`}},
+		{"unknown network", func(o *CloudServerOrder, c *OrderCatalog) {
+			o.Networks = append(o.Networks, VMNetwork{Network: "net-x"})
+		}, []string{`networks[1].network: network "net-x" does not exist`}},
+		{"network listed twice", func(o *CloudServerOrder, c *OrderCatalog) {
+			o.Networks = append(o.Networks, VMNetwork{Network: "net-a"})
+		}, []string{`networks[1].network: network "net-a" is listed more than once`}},
+		{"network without id", func(o *CloudServerOrder, c *OrderCatalog) { o.Networks[0].Network = "" },
+			[]string{"networks[0].network: is required"}},
+		{"networks not listed", func(o *CloudServerOrder, c *OrderCatalog) {
+			o.Networks[0].Network, c.Networks = "net-x", nil
+		}, nil},
+		{"no networks at all", func(o *CloudServerOrder, c *OrderCatalog) { c.Networks = []VirtualNetwork{} },
+			[]string{`networks[0].network: network "net-a" does not exist`}},
+		{"every problem", func(o *CloudServerOrder, c *OrderCatalog) {
+			o.ProductId, o.ImageId, o.StorageSize = 99, 7, "x"
+		}, []string{"productId:", "imageId:", "storageSize:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, catalog := valid, testCatalog()
+			order.Networks = append([]VMNetwork(nil), valid.Networks...)
+			tt.change(&order, &catalog)
+
+			errs := ValidateOrder(order, catalog)
+			if len(errs) != len(tt.want) {
+				t.Fatalf("got %d problems, want %d:\n%v", len(errs), len(tt.want), errs)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(errs[i].Error(), want) {
+					t.Errorf("problem %d is %q, want %q", i, errs[i].Error(), want)
+				}
+			}
+			if tt.want == nil && errs != nil {
+				t.Errorf("a valid order returned a non-nil %#v", errs)
+			}
+		})
+	}
+}
@@ -1,74 +1,80 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"github.com/edvin/oh/cache"
 )
 
-func ExecuteVirtualServerAction(vpsId int, action VirtualServerAction, body any) (VirtualServerActionResponse, error) {
+func (c *Client) ExecuteVirtualServerAction(ctx context.Context, vpsId int, action VirtualServerAction, body any) (VirtualServerActionResponse, error) {
 	path := fmt.Sprintf("servers/%d/%s", vpsId, action.String())
-	return Fetch[VirtualServerActionResponse]("POST", path, body, cache.NoCache)
+	return Mutate[VirtualServerActionResponse](ctx, c, "POST", path, body, cache.KeyCloudServers)
 }
 
-func OrderVps(order CloudServerOrder) (CloudServerOrderResponse, error) {
-	return Fetch[CloudServerOrderResponse]("POST", "servers/order", order, cache.NoCache)
+func (c *Client) OrderVps(ctx context.Context, order CloudServerOrder) (CloudServerOrderResponse, error) {
+	// the networks of the order lose the addresses given to the new server
+	return Mutate[CloudServerOrderResponse](ctx, c, "POST", "servers/order", order,
+		cache.KeyCloudServers, cache.KeyVirtualNetworks)
 }
 
-func ListVpsFlavours(serverId int) ([]CloudServerFlavour, error) {
+func (c *Client) ListVpsFlavours(ctx context.Context, serverId int) ([]CloudServerFlavour, error) {
 	path := fmt.Sprintf("servers/%d/possible-flavours", serverId)
-	return Fetch[[]CloudServerFlavour]("GET", path, nil, cache.KeyFlavours.WithArg(serverId))
+	return Fetch[[]CloudServerFlavour](ctx, c, "GET", path, nil, cache.KeyFlavours.WithArg(serverId))
 }
 
-func ListVpsProducts() ([]Product, error) {
-	return Fetch[[]Product]("GET", "products", nil, cache.KeyVpsProducts)
+func (c *Client) ListVpsProducts(ctx context.Context) ([]Product, error) {
+	return Fetch[[]Product](ctx, c, "GET", "products", nil, cache.KeyVpsProducts)
 }
 
-func ChangeVpsFlavour(serverId int, flavourId int) (ChangeFlavourResponse, error) {
+func (c *Client) ChangeVpsFlavour(ctx context.Context, serverId int, flavourId int) (ChangeFlavourResponse, error) {
 	path := fmt.Sprintf("servers/%d/change-flavour", serverId)
 	request := ChangeFlavourRequest{FlavourId: flavourId}
-	return Fetch[ChangeFlavourResponse]("POST", path, request, cache.NoCache)
+	return Mutate[ChangeFlavourResponse](ctx, c, "POST", path, request,
+		cache.KeyCloudServers, cache.KeyFlavours.WithArg(serverId))
 }
 
-func ListVpsImages() ([]CloudServerImage, error) {
-	return Fetch[[]CloudServerImage]("GET", "images", nil, cache.KeyVpsImages)
+func (c *Client) ListVpsImages(ctx context.Context) ([]CloudServerImage, error) {
+	return Fetch[[]CloudServerImage](ctx, c, "GET", "images", nil, cache.KeyVpsImages)
 }
 
-func GetVpsImage(imageId int) (CloudServerImage, error) {
+func (c *Client) GetVpsImage(ctx context.Context, imageId int) (CloudServerImage, error) {
 	url := fmt.Sprintf("images/%d", imageId)
-	return Fetch[CloudServerImage]("GET", url, nil, cache.NoCache)
+	return Fetch[CloudServerImage](ctx, c, "GET", url, nil, cache.NoCache)
 }
 
-func ListCloudServers() ([]CloudServer, error) {
-	return Fetch[[]CloudServer]("GET", "servers", nil, cache.KeyCloudServers)
+func (c *Client) ListCloudServers(ctx context.Context) ([]CloudServer, error) {
+	return Fetch[[]CloudServer](ctx, c, "GET", "servers", nil, cache.KeyCloudServers)
 }
 
-func GetVirtualServer(serverId int) (CloudServer, error) {
+func (c *Client) GetVirtualServer(ctx context.Context, serverId int) (CloudServer, error) {
 	url := fmt.Sprintf("servers/%d", serverId)
-	return Fetch[CloudServer]("GET", url, nil, cache.NoCache)
+	return Fetch[CloudServer](ctx, c, "GET", url, nil, cache.NoCache)
 }
 
-func ListVirtualNetworks() ([]VirtualNetwork, error) {
-	return Fetch[[]VirtualNetwork]("GET", "virtual-networks", nil, cache.KeyVirtualNetworks)
+func (c *Client) ListVirtualNetworks(ctx context.Context) ([]VirtualNetwork, error) {
+	return Fetch[[]VirtualNetwork](ctx, c, "GET", "virtual-networks", nil, cache.KeyVirtualNetworks)
 }
 
-func ListAttachedVirtualNetworks(serverId int) ([]AttachedNetwork, error) {
+func (c *Client) ListAttachedVirtualNetworks(ctx context.Context, serverId int) ([]AttachedNetwork, error) {
 	path := fmt.Sprintf("servers/%d/networks", serverId)
-	networks, err := Fetch[[]AttachedNetwork]("GET", path, nil, cache.KeyAttachedNetworks.WithArg(serverId))
+	networks, err := Fetch[[]AttachedNetwork](ctx, c, "GET", path, nil, cache.KeyAttachedNetworks.WithArg(serverId))
 	return networks, err
 }
 
-func DetachVirtualNetwork(vpsId int, networkId string) (DetachVirtualNetworkResponse, error) {
+func (c *Client) DetachVirtualNetwork(ctx context.Context, vpsId int, networkId string) (DetachVirtualNetworkResponse, error) {
 	path := fmt.Sprintf("servers/%d/detach-network", vpsId)
 	request := DetachVirtualNetworkRequest{NetworkId: networkId}
-	return Fetch[DetachVirtualNetworkResponse]("POST", path, request, cache.NoCache)
+	return Mutate[DetachVirtualNetworkResponse](ctx, c, "POST", path, request,
+		cache.KeyCloudServers, cache.KeyAttachedNetworks.WithArg(vpsId), cache.KeyVirtualNetworks)
 }
 
-func AttachVirtualNetwork(vpsId int, networkId string, ipv4 string, ipv6 string) (AttachVirtualNetworkResponse, error) {
+func (c *Client) AttachVirtualNetwork(ctx context.Context, vpsId int, networkId string, ipv4 string, ipv6 string) (AttachVirtualNetworkResponse, error) {
 	path := fmt.Sprintf("servers/%d/attach-network", vpsId)
 	request := AttachVirtualNetworkRequest{
 		NetworkId: networkId,
 		IPv4:      ipv4,
 		IPv6:      ipv6,
 	}
-	return Fetch[AttachVirtualNetworkResponse]("POST", path, request, cache.NoCache)
+	return Mutate[AttachVirtualNetworkResponse](ctx, c, "POST", path, request,
+		cache.KeyCloudServers, cache.KeyAttachedNetworks.WithArg(vpsId), cache.KeyVirtualNetworks)
 }
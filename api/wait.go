@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StatusDeleted is the pseudo status of a server that no longer exists
+const StatusDeleted = "deleted"
+
+// WaitOptions control how often WaitForStatus and WaitForNetwork poll the API
+type WaitOptions struct {
+	// Interval is the delay before the second poll, it grows by half with every poll
+	Interval time.Duration
+	// MaxInterval caps the delay between polls
+	MaxInterval time.Duration
+	// OnPoll is called with the observed state after every poll, it may be nil
+	OnPoll func(observed string)
+	// From is the status of the server before the change. WaitForStatus only accepts the
+	// status it waits for once the server has left From, because a reboot or a flavour
+	// change starts and ends in the same status and takes a moment to begin.
+	From string
+	// Settle is how long the server may stay in From before the change is taken to
+	// have been too quick to observe
+	Settle time.Duration
+}
+
+var DefaultWaitOptions = WaitOptions{
+	Interval:    2 * time.Second,
+	MaxInterval: 15 * time.Second,
+	Settle:      time.Minute,
+}
+
+// Poll calls check until it reports done, returns an error or ctx is done
+func Poll(ctx context.Context, opts WaitOptions, check func() (bool, error)) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWaitOptions.Interval
+	}
+	for {
+		done, err := check()
+		if err != nil || done {
+			return err
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		interval += interval / 2
+		if opts.MaxInterval > 0 && interval > opts.MaxInterval {
+			interval = opts.MaxInterval
+		}
+	}
+}
+
+// WaitForStatus polls the server until its status equals status. With StatusDeleted it
+// waits until the server is gone. With opts.From it first waits for the server to leave
+// that status. The last observed server is returned.
+func (c *Client) WaitForStatus(ctx context.Context, serverId int, status string, opts WaitOptions) (CloudServer, error) {
+	var server CloudServer
+	left := opts.From == ""
+	start := time.Now()
+	err := Poll(ctx, opts, func() (bool, error) {
+		s, err := c.GetVirtualServer(ctx, serverId)
+		if status == StatusDeleted && isNotFound(err) {
+			observe(opts, StatusDeleted)
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		server = s
+		observe(opts, s.Status)
+		if s.Status != opts.From || opts.Settle > 0 && time.Since(start) >= opts.Settle {
+			left = true
+		}
+		return left && s.Status == status, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return server, fmt.Errorf("timed out waiting for server %d to become %s, last status %q", serverId, status, server.Status)
+	}
+	return server, err
+}
+
+// WaitForNetwork polls the networks attached to the server until the network is attached, or detached
+func (c *Client) WaitForNetwork(ctx context.Context, serverId int, networkId string, attached bool, opts WaitOptions) error {
+	want := "attached"
+	if !attached {
+		want = "detached"
+	}
+	err := Poll(ctx, opts, func() (bool, error) {
+		networks, err := c.ListAttachedVirtualNetworks(ctx, serverId)
+		if err != nil {
+			return false, err
+		}
+		found := false
+		for _, n := range networks {
+			if n.Id == networkId {
+				found = true
+				break
+			}
+		}
+		if found {
+			observe(opts, "attached")
+		} else {
+			observe(opts, "detached")
+		}
+		return found == attached, nil
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("timed out waiting for network %s to be %s on server %d", networkId, want, serverId)
+	}
+	return err
+}
+
+func observe(opts WaitOptions, observed string) {
+	if opts.OnPoll != nil {
+		opts.OnPoll(observed)
+	}
+}
+
+func isNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
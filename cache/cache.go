@@ -2,10 +2,14 @@ package cache
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/edvin/oh/config"
 	"github.com/spf13/viper"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
@@ -32,12 +36,17 @@ const (
 
 const NoCache = CacheKey("")
 
+// ErrInvalidKey is returned for keys that would point outside of the cache directory
+var ErrInvalidKey = errors.New("invalid cache key")
+
 type cacheEntry[T any] struct {
-	Timestamp time.Time `json:"ts"`
-	Data      T         `json:"data"`
+	Timestamp time.Time     `json:"ts"`
+	TTL       time.Duration `json:"ttl,omitempty"`
+	Data      T             `json:"data"`
 }
 
-func cacheFilePath(key CacheKey) (string, error) {
+// SharedDir returns the cache directory of oh that all profiles share, creating it if needed
+func SharedDir() (string, error) {
 	base, err := os.UserCacheDir()
 	if err != nil {
 		return "", err
@@ -46,6 +55,35 @@ func cacheFilePath(key CacheKey) (string, error) {
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return "", err
 	}
+	return dir, nil
+}
+
+// Dir returns the directory holding the cache files of the active profile, creating it if needed.
+// The default profile uses the top-level directory so existing caches stay valid.
+func Dir() (string, error) {
+	dir, err := SharedDir()
+	if err != nil {
+		return "", err
+	}
+	if p := config.Profile(); p != config.DefaultProfile {
+		dir = filepath.Join(dir, "profiles", p)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// cacheFilePath returns the file of key in the cache directory. Keys given by the user
+// must not reach outside of it.
+func cacheFilePath(key CacheKey) (string, error) {
+	if strings.ContainsAny(string(key), `/\`) || strings.Contains(string(key), "..") {
+		return "", fmt.Errorf("%w %q: it must not contain path separators or ..", ErrInvalidKey, key)
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
 	return filepath.Join(dir, string(key)+".json"), nil
 }
 
@@ -57,8 +95,7 @@ func loadEntry[T any](path string) (*cacheEntry[T], error) {
 	}
 	var e cacheEntry[T]
 	if err := json.Unmarshal(b, &e); err != nil {
-		fmt.Println(err)
-		return nil, err
+		return nil, fmt.Errorf("cache file %s is corrupt: %w", path, err)
 	}
 	return &e, nil
 }
@@ -97,12 +134,36 @@ func Call[T any](key CacheKey, ttl time.Duration, fetch func() (T, error)) (T, e
 		return zero, err
 	}
 
-	entry := &cacheEntry[T]{Timestamp: time.Now(), Data: fresh}
+	entry := &cacheEntry[T]{Timestamp: time.Now(), TTL: ttl, Data: fresh}
 	_ = saveEntry(path, entry) // best-effort
 
 	return fresh, nil
 }
 
+// Invalidate removes the given keys from the cache. Keys may contain glob patterns,
+// e.g. KeyFlavours.WithArg("*") removes the flavours of every server. Keys that are not cached are ignored.
+func Invalidate(keys ...CacheKey) error {
+	for _, key := range keys {
+		if key == NoCache {
+			continue
+		}
+		pattern, err := cacheFilePath(key)
+		if err != nil {
+			return err
+		}
+		paths, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid cache key %q: %w", key, err)
+		}
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // Store explicitly overwrites the cache
 func Store[T any](key CacheKey, data T) error {
 	path, err := cacheFilePath(key)
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Info describes a cache entry on disk
+type Info struct {
+	Key       CacheKey      `json:"key"`
+	Path      string        `json:"path"`
+	Size      int64         `json:"size"`
+	Timestamp time.Time     `json:"timestamp"`
+	TTL       time.Duration `json:"ttl"`
+}
+
+func (i Info) Age() time.Duration {
+	return time.Since(i.Timestamp)
+}
+
+// ExpiresIn is the time left until the entry expires, negative if it already has
+func (i Info) ExpiresIn() time.Duration {
+	return i.TTL - i.Age()
+}
+
+func (i Info) Expired() bool {
+	return i.ExpiresIn() <= 0
+}
+
+// Entry is a cache entry with its data left undecoded
+type Entry struct {
+	Info
+	Data json.RawMessage `json:"data"`
+}
+
+// List returns information about every entry in the cache, sorted by key
+func List() ([]Info, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]Info, 0, len(paths))
+	for _, path := range paths {
+		key := CacheKey(strings.TrimSuffix(filepath.Base(path), ".json"))
+		e, err := load(key, path)
+		if err != nil {
+			// Skip files that are not cache entries
+			continue
+		}
+		infos = append(infos, e.Info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	return infos, nil
+}
+
+// Load reads a single cache entry
+func Load(key CacheKey) (Entry, error) {
+	path, err := cacheFilePath(key)
+	if err != nil {
+		return Entry{}, err
+	}
+	return load(key, path)
+}
+
+func load(key CacheKey, path string) (Entry, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	raw, err := loadEntry[json.RawMessage](path)
+	if err != nil {
+		return Entry{}, err
+	}
+	ttl := raw.TTL
+	if ttl == 0 {
+		// Entries stored without a TTL are read back by Call with the default
+		ttl = DefaultTTL
+	}
+	return Entry{
+		Info: Info{
+			Key:       key,
+			Path:      path,
+			Size:      stat.Size(),
+			Timestamp: raw.Timestamp,
+			TTL:       ttl,
+		},
+		Data: raw.Data,
+	}, nil
+}
+
+// PurgeExpired removes every entry that has outlived its TTL and returns the removed keys
+func PurgeExpired() ([]CacheKey, error) {
+	infos, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var purged []CacheKey
+	for _, i := range infos {
+		if i.Expired() {
+			if err := os.Remove(i.Path); err != nil {
+				return purged, err
+			}
+			purged = append(purged, i.Key)
+		}
+	}
+	return purged, nil
+}
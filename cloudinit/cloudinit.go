@@ -0,0 +1,213 @@
+// Package cloudinit assembles and checks cloud-init user-data. Several local files,
+// or an #include file listing them, are combined into a MIME multi-part archive the
+// way cloud-init's make-mime does, so a server can be bootstrapped with e.g. a
+// cloud-config and a shell script at once.
+package cloudinit
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// boundary is fixed so the same files always give the same user-data, e.g. in --dry-run output
+const boundary = "==oh-user-data-boundary=="
+
+// MaxSize is the most user-data a server accepts, the limit most clouds apply
+const MaxSize = 16 * 1024
+
+// maxIncludeDepth stops #include files from including each other forever
+const maxIncludeDepth = 10
+
+// part is a single piece of user-data
+type part struct {
+	name    string
+	content string
+}
+
+// Load reads the user-data files and returns the user-data to send. A single file is
+// returned as-is, several files or an #include file listing local files are combined
+// into a multi-part archive. URLs in an #include file are kept for cloud-init to fetch.
+func Load(paths ...string) (string, error) {
+	var parts []part
+	for _, p := range paths {
+		loaded, err := loadFile(p, 0)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, loaded...)
+	}
+
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0].content, nil
+	default:
+		return assemble(parts)
+	}
+}
+
+func loadFile(path string, depth int) ([]part, error) {
+	if depth > maxIncludeDepth {
+		return nil, fmt.Errorf("user-data %s: #include nested more than %d levels", path, maxIncludeDepth)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read user-data: %w", err)
+	}
+	content := string(b)
+	if !strings.HasPrefix(content, "#include") {
+		return []part{{name: filepath.Base(path), content: content}}, nil
+	}
+
+	var parts []part
+	var urls []string
+	for _, line := range strings.Split(content, "\n")[1:] {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.Contains(line, "://"):
+			urls = append(urls, line)
+		default:
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(filepath.Dir(path), line)
+			}
+			included, err := loadFile(line, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("included from %s: %w", path, err)
+			}
+			parts = append(parts, included...)
+		}
+	}
+	if len(urls) > 0 {
+		parts = append(parts, part{
+			name:    filepath.Base(path),
+			content: "#include\n" + strings.Join(urls, "\n") + "\n",
+		})
+	}
+	return parts, nil
+}
+
+func assemble(parts []part) (string, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=\"%s\"\n", boundary)
+	b.WriteString("MIME-Version: 1.0\n\n")
+
+	w := multipart.NewWriter(&b)
+	if err := w.SetBoundary(boundary); err != nil {
+		return "", err
+	}
+	for _, p := range parts {
+		if strings.Contains(p.content, boundary) {
+			return "", fmt.Errorf("user-data %s contains the multi-part boundary %q", p.name, boundary)
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", ContentType(p.content)+`; charset="utf-8"`)
+		header.Set("MIME-Version", "1.0")
+		header.Set("Content-Transfer-Encoding", "8bit")
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": p.name}))
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.WriteString(pw, p.content); err != nil {
+			return "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ContentType returns the MIME type cloud-init gives user-data, based on its first line
+func ContentType(content string) string {
+	first, _, _ := strings.Cut(content, "\n")
+	first = strings.TrimSpace(first)
+	switch {
+	case strings.HasPrefix(first, "#cloud-config-archive"):
+		return "text/cloud-config-archive"
+	case strings.HasPrefix(first, "#cloud-config"):
+		return "text/cloud-config"
+	case strings.HasPrefix(first, "#cloud-boothook"):
+		return "text/cloud-boothook"
+	case strings.HasPrefix(first, "#include"):
+		return "text/x-include-url"
+	case strings.HasPrefix(first, "#part-handler"):
+		return "text/part-handler"
+	case strings.HasPrefix(first, "## template: jinja"):
+		return "text/jinja2"
+	case strings.HasPrefix(first, "#!"):
+		return "text/x-shellscript"
+	default:
+		return "text/plain"
+	}
+}
+
+// Validate checks user-data locally: it must fit in MaxSize, and every cloud-config,
+// on its own or as part of a multi-part archive, must be a YAML mapping
+func Validate(userData string) error {
+	if len(userData) > MaxSize {
+		return fmt.Errorf("user-data is %d bytes, at most %d are allowed", len(userData), MaxSize)
+	}
+	if strings.HasPrefix(userData, "Content-Type:") || strings.HasPrefix(userData, "MIME-Version:") {
+		return validateMultipart(userData)
+	}
+	return validatePart("user-data", userData)
+}
+
+func validateMultipart(userData string) error {
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		return fmt.Errorf("invalid multi-part user-data: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return fmt.Errorf("invalid multi-part user-data: unexpected content type %q", msg.Header.Get("Content-Type"))
+	}
+
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid multi-part user-data: %w", err)
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			return fmt.Errorf("invalid multi-part user-data: %w", err)
+		}
+		name := p.FileName()
+		if name == "" {
+			name = "part"
+		}
+		if err := validatePart(name, string(content)); err != nil {
+			return err
+		}
+	}
+}
+
+func validatePart(name, content string) error {
+	if ContentType(content) != "text/cloud-config" {
+		return nil
+	}
+	var config any
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		return fmt.Errorf("%s is not valid YAML: %w", name, err)
+	}
+	if _, ok := config.(map[string]any); config != nil && !ok {
+		return fmt.Errorf("%s must be a YAML mapping of cloud-config modules", name)
+	}
+	return nil
+}
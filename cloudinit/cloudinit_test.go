@@ -0,0 +1,212 @@
+package cloudinit
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	cloudConfig = "#cloud-config\npackages:\n  - nginx\n"
+	script      = "#!/bin/sh\necho hello\n"
+)
+
+// writeFiles writes the files to a temporary directory and returns it
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// mimePart is a part of a multi-part archive
+type mimePart struct {
+	contentType string
+	filename    string
+	content     string
+}
+
+func readMultipart(t *testing.T, userData string) []mimePart {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(userData))
+	if err != nil {
+		t.Fatalf("not a MIME message: %v\n%s", err, userData)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type %q: %v", msg.Header.Get("Content-Type"), err)
+	}
+	var parts []mimePart
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, mimePart{p.Header.Get("Content-Type"), p.FileName(), string(content)})
+	}
+}
+
+func TestLoadSingleFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"cloud-config.yaml": cloudConfig, "setup.sh": script})
+	for name, want := range map[string]string{"cloud-config.yaml": cloudConfig, "setup.sh": script} {
+		got, err := Load(filepath.Join(dir, name))
+		if err != nil || got != want {
+			t.Errorf("Load(%s) = %q, %v, want the file as-is", name, got, err)
+		}
+		if err := Validate(got); err != nil {
+			t.Errorf("Validate(%s): %v", name, err)
+		}
+	}
+
+	if got, err := Load(); got != "" || err != nil {
+		t.Errorf("Load() = %q, %v", got, err)
+	}
+	if _, err := Load(filepath.Join(dir, "missing")); err == nil || !strings.HasPrefix(err.Error(), "cannot read user-data:") {
+		t.Errorf("Load of a missing file: %v", err)
+	}
+}
+
+func TestLoadMultipart(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"cloud-config.yaml": cloudConfig, "setup.sh": script})
+
+	userData, err := Load(filepath.Join(dir, "cloud-config.yaml"), filepath.Join(dir, "setup.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := Load(filepath.Join(dir, "cloud-config.yaml"), filepath.Join(dir, "setup.sh")); again != userData {
+		t.Error("the same files gave different user-data")
+	}
+	want := []mimePart{
+		{`text/cloud-config; charset="utf-8"`, "cloud-config.yaml", cloudConfig},
+		{`text/x-shellscript; charset="utf-8"`, "setup.sh", script},
+	}
+	if got := readMultipart(t, userData); !slices.Equal(got, want) {
+		t.Errorf("parts %q, want %q", got, want)
+	}
+	if err := Validate(userData); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+}
+
+func TestLoadInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"cloud-config.yaml": cloudConfig,
+		"setup.sh":          script,
+		"more.txt":          "#include\nsetup.sh\n",
+		"loop.txt":          "#include\nloop.txt\n",
+		"broken.txt":        "#include\nmissing.sh\n",
+	})
+	include := "#include\n# the base config\ncloud-config.yaml\n\n" + filepath.Join(dir, "more.txt") + "\n  https://example.com/extra.yaml\n"
+	if err := os.WriteFile(filepath.Join(dir, "bootstrap.txt"), []byte(include), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	userData, err := Load(filepath.Join(dir, "bootstrap.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []mimePart{
+		{`text/cloud-config; charset="utf-8"`, "cloud-config.yaml", cloudConfig},
+		{`text/x-shellscript; charset="utf-8"`, "setup.sh", script},
+		{`text/x-include-url; charset="utf-8"`, "bootstrap.txt", "#include\nhttps://example.com/extra.yaml\n"},
+	}
+	if got := readMultipart(t, userData); !slices.Equal(got, want) {
+		t.Errorf("parts %q, want %q", got, want)
+	}
+
+	// a single included file needs no archive
+	if got, err := Load(filepath.Join(dir, "more.txt")); got != script || err != nil {
+		t.Errorf("Load(more.txt) = %q, %v", got, err)
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"loop.txt", "#include nested more than 10 levels"},
+		{"broken.txt", "included from " + filepath.Join(dir, "broken.txt") + ": cannot read user-data:"},
+	}
+	for _, tt := range tests {
+		if _, err := Load(filepath.Join(dir, tt.file)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%s): got error %v, want %q", tt.file, err, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"broken.yaml": "#cloud-config\npackages: [nginx\n",
+		"setup.sh":    "#!/bin/sh\necho [\n",
+	})
+	multi, err := Load(filepath.Join(dir, "setup.sh"), filepath.Join(dir, "broken.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		userData string
+		want     string
+	}{
+		{cloudConfig, ""},
+		{"#cloud-config\n", ""},
+		// only cloud-configs are YAML
+		{"#!/bin/sh\necho [\n", ""},
+		{"#cloud-config\npackages: [nginx\n", "user-data is not valid YAML"},
+		{"#cloud-config\n- nginx\n", "user-data must be a YAML mapping of cloud-config modules"},
+		{multi, "broken.yaml is not valid YAML"},
+		{"Content-Type: text/plain\n\nhello\n", `invalid multi-part user-data: unexpected content type "text/plain"`},
+		{"#!/bin/sh\n" + strings.Repeat("#", MaxSize-10), ""},
+		{"#!/bin/sh\n" + strings.Repeat("#", MaxSize-9), "user-data is 16385 bytes, at most 16384 are allowed"},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.userData)
+		if tt.want == "" && err != nil {
+			t.Errorf("Validate(%.40q): %v", tt.userData, err)
+		}
+		if tt.want != "" && (err == nil || !strings.HasPrefix(err.Error(), tt.want)) {
+			t.Errorf("Validate(%.40q): got error %v, want %q", tt.userData, err, tt.want)
+		}
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{cloudConfig, "text/cloud-config"},
+		{"#cloud-config-archive\n- type: text/x-shellscript\n", "text/cloud-config-archive"},
+		{"#cloud-boothook\n#!/bin/sh\n", "text/cloud-boothook"},
+		{"#include\nhttps://example.com/a\n", "text/x-include-url"},
+		{"#part-handler\n", "text/part-handler"},
+		{"## template: jinja\n#cloud-config\n", "text/jinja2"},
+		{script, "text/x-shellscript"},
+		{"#!/usr/bin/env python3", "text/x-shellscript"},
+		{"  #cloud-config\r\n", "text/cloud-config"},
+		{"hello\n", "text/plain"},
+		{"", "text/plain"},
+	}
+	for _, tt := range tests {
+		if got := ContentType(tt.content); got != tt.want {
+			t.Errorf("ContentType(%q) = %s, want %s", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/edvin/oh/cache"
+	"github.com/edvin/oh/ui"
+	"github.com/spf13/cobra"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+var (
+	purgeAll     bool
+	purgeExpired bool
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect and purge the on-disk cache of API responses",
+}
+
+var listCacheCmd = &cobra.Command{
+	Use:               "list",
+	Short:             "List cached API responses",
+	Long:              `Lists every cache entry with its age, size and time left until it expires.`,
+	SilenceUsage:      true,
+	ValidArgsFunction: NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		infos, err := cache.List()
+		if err != nil {
+			return err
+		}
+
+		return printList(cmd, infos, cacheColumns()...)
+	},
+}
+
+var showCacheCmd = &cobra.Command{
+	Use:               "show <key>",
+	Short:             "Show a cached API response",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeCacheKeys,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entry, err := cache.Load(cache.CacheKey(args[0]))
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("no cache entry %q", args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		if printed, err := printValue(entry, cmd); printed {
+			return err
+		}
+
+		if err := ui.RenderForm(entry.Info, cacheColumns()...); err != nil {
+			return err
+		}
+		var data bytes.Buffer
+		if err := json.Indent(&data, entry.Data, "", "  "); err != nil {
+			return fmt.Errorf("cache entry %q is corrupt: %w", entry.Key, err)
+		}
+		fmt.Printf("Data: %s\n", data.String())
+		return nil
+	},
+}
+
+var purgeCacheCmd = &cobra.Command{
+	Use:   "purge [key]",
+	Short: "Remove cached API responses",
+	Example: `  # remove a single entry
+  oh cache purge cloud_servers
+
+  # remove entries that have outlived their TTL
+  oh cache purge --expired
+
+  # remove everything
+  oh cache purge --all`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeCacheKeys,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var purged []cache.CacheKey
+
+		switch {
+		case purgeAll:
+			infos, err := cache.List()
+			if err != nil {
+				return err
+			}
+			if err := cache.Invalidate(cache.CacheKey("*")); err != nil {
+				return err
+			}
+			for _, i := range infos {
+				purged = append(purged, i.Key)
+			}
+		case purgeExpired:
+			var err error
+			if purged, err = cache.PurgeExpired(); err != nil {
+				return err
+			}
+		case len(args) == 1:
+			key := cache.CacheKey(args[0])
+			// a corrupt entry can still be purged
+			if _, err := cache.Load(key); errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("no cache entry %q", key)
+			} else if errors.Is(err, cache.ErrInvalidKey) {
+				return err
+			}
+			if err := cache.Invalidate(key); err != nil {
+				return err
+			}
+			purged = append(purged, key)
+		default:
+			return fmt.Errorf("you must specify a key, --all or --expired")
+		}
+
+		if printed, err := printValue(purged, cmd); printed {
+			return err
+		}
+
+		cmd.Printf("🧹 Purged %d cache entries\n", len(purged))
+		return nil
+	},
+}
+
+var pathCacheCmd = &cobra.Command{
+	Use:               "path",
+	Short:             "Print the cache directory",
+	SilenceUsage:      true,
+	ValidArgsFunction: NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := cache.Dir()
+		if err != nil {
+			return err
+		}
+
+		if printed, err := printValue(dir, cmd); printed {
+			return err
+		}
+
+		fmt.Println(dir)
+		return nil
+	},
+}
+
+func init() {
+	purgeCacheCmd.Flags().BoolVar(&purgeAll, "all", false, "Remove all cache entries")
+	purgeCacheCmd.Flags().BoolVar(&purgeExpired, "expired", false, "Remove only expired cache entries")
+	purgeCacheCmd.MarkFlagsMutuallyExclusive("all", "expired")
+
+	addListFlags(listCacheCmd, ui.ColumnKeys(cacheColumns()))
+	cacheCmd.AddCommand(listCacheCmd, showCacheCmd, purgeCacheCmd, pathCacheCmd)
+	rootCmd.AddCommand(cacheCmd)
+}
+
+func cacheColumns() []ui.TableColumn[cache.Info] {
+	return []ui.TableColumn[cache.Info]{
+		ui.Column("Key", func(i cache.Info) cache.CacheKey { return i.Key }),
+		ui.Column("Age", func(i cache.Info) time.Duration { return i.Age().Round(time.Second) }),
+		ui.Column("Size", func(i cache.Info) ui.Bytes { return ui.Bytes(i.Size) }),
+		ui.Column("TTL", func(i cache.Info) time.Duration { return i.TTL }),
+		ui.Column("Expires", func(i cache.Info) string {
+			if i.Expired() {
+				return "expired"
+			}
+			return "in " + i.ExpiresIn().Round(time.Second).String()
+		}),
+	}
+}
+
+func completeCacheKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	infos, err := cache.List()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var comps []string
+	for _, i := range infos {
+		if strings.HasPrefix(string(i.Key), toComplete) {
+			comps = append(comps, string(i.Key))
+		}
+	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/edvin/oh/cache"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCacheListSkipsForeignFiles(t *testing.T) {
+	env := newTestEnv(t, nil)
+	env.mustRun("vps", "list")
+
+	dir := env.mustRun("cache", "path").stdout
+	if err := os.WriteFile(filepath.Join(strings.TrimSpace(dir), "notes.json"), []byte("not a cache entry"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := env.mustRun("cache", "list", "-o", "json")
+	var infos []cache.Info
+	if err := json.Unmarshal([]byte(r.stdout), &infos); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+	}
+	if len(infos) != 1 || infos[0].Key != cache.KeyCloudServers {
+		t.Errorf("cache list = %v, want just %s", infos, cache.KeyCloudServers)
+	}
+
+	// a corrupt entry is reported by show, and can be purged
+	if r := env.run("cache", "show", "notes"); r.code != 1 || !strings.Contains(r.stderr, "corrupt") {
+		t.Errorf("show of a corrupt entry: exit %d, stderr %q", r.code, r.stderr)
+	}
+	env.mustRun("cache", "purge", "notes")
+}
+
+func TestCacheKeysStayInTheCacheDir(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	outside := filepath.Join(filepath.Dir(strings.TrimSpace(env.mustRun("cache", "path").stdout)), "x.json")
+	if err := os.WriteFile(outside, []byte(`{"ts":"2024-01-01T00:00:00Z","data":{}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cmd := range []string{"show", "purge"} {
+		for _, key := range []string{"../x", `..\x`, "a/b", ".."} {
+			r := env.run("cache", cmd, key)
+			if r.code != 1 || !strings.Contains(r.stderr, "invalid cache key") {
+				t.Errorf("cache %s %s: exit %d, stderr %q", cmd, key, r.code, r.stderr)
+			}
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside the cache is gone: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/cache"
+	"github.com/edvin/oh/config"
+	"github.com/edvin/oh/credentials"
+	"github.com/edvin/oh/ui"
+	tokenui "github.com/edvin/oh/ui/token"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	client     *api.Client
+	clientOnce sync.Once
+)
+
+// apiClient returns the api.Client configured from the config file, environment and flags.
+// The client is created on first use, after the configuration has been read.
+func apiClient() *api.Client {
+	clientOnce.Do(func() {
+		client = api.NewClient(viper.GetString("base_url"), tokenSource())
+		client.HTTPClient = &http.Client{
+			Transport: &api.RetryTransport{
+				Base: &api.RateLimitTransport{
+					Base:    &api.TraceTransport{Base: http.DefaultTransport, Level: traceLevel(), Out: os.Stderr},
+					Limiter: rateLimiter(),
+				},
+				Policy:  retryPolicy(),
+				Timeout: viper.GetDuration("request_timeout"),
+				Logf:    verbosef,
+			},
+		}
+		if viper.GetBool("dry_run") {
+			client.DryRun = os.Stdout
+		}
+	})
+	return client
+}
+
+// tokenSource resolves the API token: a plaintext token from the config file or environment
+// wins, otherwise the credential store referenced by token_ref is asked on first use
+func tokenSource() api.TokenSource {
+	if token := viper.GetString("token"); token != "" {
+		return api.StaticToken(token)
+	}
+	ref := viper.GetString("token_ref")
+	if ref == "" {
+		return api.StaticToken("")
+	}
+	return api.CachedToken(api.TokenFunc(func() (string, error) {
+		store, err := credentials.Open(ref, credentialOptions())
+		if err != nil {
+			return "", err
+		}
+		return store.Token()
+	}))
+}
+
+// tokenSourceName describes where tokenSource gets the token from
+func tokenSourceName() string {
+	if _, ok := os.LookupEnv("TOKEN"); ok {
+		return "environment variable TOKEN"
+	}
+	if viper.GetString("token") != "" {
+		return "config file " + configFileName()
+	}
+	if ref := viper.GetString("token_ref"); ref != "" {
+		return ref
+	}
+	return "none"
+}
+
+func configFileName() string {
+	path, err := config.File()
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+// credentialOptions configures credential stores for the active profile
+func credentialOptions() credentials.Options {
+	return credentials.Options{
+		Profile: config.Profile(),
+		Passphrase: func(confirm bool) (string, error) {
+			if p := os.Getenv("OH_PASSPHRASE"); p != "" {
+				return p, nil
+			}
+			if !ui.IsTerminal(os.Stdin) {
+				return "", fmt.Errorf("a passphrase is required but stdin is not a terminal; set OH_PASSPHRASE")
+			}
+			return tokenui.RequestPassphrase(confirm)
+		},
+	}
+}
+
+// retryPolicy reads the retry.* settings, falling back to api.DefaultRetryPolicy
+func retryPolicy() api.RetryPolicy {
+	policy := api.DefaultRetryPolicy
+	if viper.IsSet("retry.max_attempts") {
+		policy.MaxAttempts = viper.GetInt("retry.max_attempts")
+	}
+	if viper.IsSet("retry.base_delay") {
+		policy.BaseDelay = viper.GetDuration("retry.base_delay")
+	}
+	if viper.IsSet("retry.max_delay") {
+		policy.MaxDelay = viper.GetDuration("retry.max_delay")
+	}
+	if viper.IsSet("retry.max_elapsed") {
+		policy.MaxElapsed = viper.GetDuration("retry.max_elapsed")
+	}
+	return policy
+}
+
+// rateLimiter returns a token bucket shared by all oh processes on this machine that use
+// the same API, whatever their profile, or nil if rate_limit.rps is zero
+func rateLimiter() api.RateLimiter {
+	rps := viper.GetFloat64("rate_limit.rps")
+	if rps <= 0 {
+		return nil
+	}
+	burst := viper.GetInt("rate_limit.burst")
+
+	dir, err := cache.SharedDir()
+	if err != nil {
+		// Without a shared lock file we can still throttle this process
+		verbosef("rate limit is not shared between processes: %v", err)
+		return api.NewTokenBucket(rps, burst)
+	}
+	limiter := api.NewSharedTokenBucket(filepath.Join(dir, rateLimitFile(viper.GetString("base_url"))), rps, burst)
+	limiter.Logf = verbosef
+	return limiter
+}
+
+// rateLimitFile names the lock file of the API host, e.g. ratelimit-api.example.com.lock
+func rateLimitFile(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return "ratelimit.lock"
+	}
+	host := strings.Map(func(r rune) rune {
+		if r == ':' || r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, u.Host)
+	return "ratelimit-" + host + ".lock"
+}
+
+// traceLevel maps the --verbose and --debug flags to an api.TraceLevel
+func traceLevel() api.TraceLevel {
+	switch {
+	case viper.GetBool("debug"):
+		return api.TraceDebug
+	case viper.GetBool("verbose"):
+		return api.TraceVerbose
+	default:
+		return api.TraceOff
+	}
+}
+
+// verbosef writes a diagnostic line to stderr when --verbose or --debug is set
+func verbosef(format string, args ...any) {
+	if traceLevel() >= api.TraceVerbose {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+	}
+}
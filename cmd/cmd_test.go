@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"github.com/edvin/oh/api/apitest"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// result is what a command run through rootCmd printed, and its exit status
+type result struct {
+	stdout string
+	stderr string
+	code   int
+}
+
+// testEnv runs commands against a fake API, with a config file and caches of its own
+type testEnv struct {
+	t      *testing.T
+	fake   *apitest.Fake
+	config string
+}
+
+// newTestEnv starts a fake API serving fake, wrapped by wrap if it is not nil
+func newTestEnv(t *testing.T, wrap func(http.Handler) http.Handler) *testEnv {
+	t.Helper()
+	fake := apitest.NewFake()
+	handler := fake.Handler()
+	if wrap != nil {
+		handler = wrap(handler)
+	}
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("BASE_URL", srv.URL+"/")
+	t.Setenv("TOKEN", apitest.DefaultToken)
+	t.Setenv("OH_PROFILE", "")
+
+	env := &testEnv{t: t, fake: fake, config: filepath.Join(home, ".oh.yaml")}
+	env.writeConfig("")
+	return env
+}
+
+// writeConfig replaces the config file
+func (e *testEnv) writeConfig(yaml string) {
+	e.t.Helper()
+	if err := os.WriteFile(e.config, []byte(yaml), 0o600); err != nil {
+		e.t.Fatal(err)
+	}
+}
+
+// run executes oh with args, the way main does, and captures its output
+func (e *testEnv) run(args ...string) result {
+	e.t.Helper()
+	resetFlags(rootCmd)
+	client, clientOnce = nil, sync.Once{}
+	// commands override settings in viper, e.g. config.Set, which would outlive the process
+	for _, key := range []string{"profile", "token", "token_ref"} {
+		viper.Set(key, nil)
+	}
+
+	stdout, stderr := e.capture("stdout"), e.capture("stderr")
+	origOut, origErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdout, stderr
+	defer func() { os.Stdout, os.Stderr = origOut, origErr }()
+
+	rootCmd.SetArgs(append([]string{"--config", e.config}, args...))
+	code := exitCode(rootCmd.Execute())
+
+	return result{stdout: e.read(stdout), stderr: e.read(stderr), code: code}
+}
+
+func (e *testEnv) capture(name string) *os.File {
+	e.t.Helper()
+	f, err := os.CreateTemp(e.t.TempDir(), name)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	e.t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func (e *testEnv) read(f *os.File) string {
+	e.t.Helper()
+	b, err := os.ReadFile(f.Name())
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return string(b)
+}
+
+// mustRun runs oh and fails the test unless it exits with 0
+func (e *testEnv) mustRun(args ...string) result {
+	e.t.Helper()
+	r := e.run(args...)
+	if r.code != 0 {
+		e.t.Fatalf("oh %s: exit status %d\nstdout:\n%s\nstderr:\n%s", strings.Join(args, " "), r.code, r.stdout, r.stderr)
+	}
+	return r
+}
+
+// resetFlags sets every flag back to its default, as if the process had just started
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			_ = sv.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(reset)
+	cmd.PersistentFlags().VisitAll(reset)
+	// forgets where a -- was, which ArgsLenAtDash would report otherwise
+	cmd.Flags().Init(cmd.Flags().Name(), pflag.ContinueOnError)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+// status returns the status of a server of the fake API
+func (e *testEnv) status(id int) string {
+	e.fake.Lock()
+	defer e.fake.Unlock()
+	return e.fake.Servers[id].Status
+}
+
+func (e *testEnv) requests() []apitest.RecordedRequest {
+	e.fake.Lock()
+	defer e.fake.Unlock()
+	return append([]apitest.RecordedRequest(nil), e.fake.Requests...)
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/huh"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/ui"
+	vpsui "github.com/edvin/oh/ui/vps"
+	"github.com/spf13/viper"
+	"os"
+)
+
+// assumeYes skips confirmation prompts
+var assumeYes bool
+
+// destructiveActions interrupt service or destroy data, and must be confirmed
+var destructiveActions = map[api.VirtualServerAction]bool{
+	api.VirtualServerHardReboot: true,
+	api.VirtualServerPowerOff:   true,
+	api.VirtualServerReset:      true,
+}
+
+var errNotConfirmed = errors.New("aborted, nothing was changed")
+
+// confirm asks before running action on the servers, unless --yes was given.
+// With byName, the name of the (single) server has to be typed back.
+// Without a terminal on stdin there is nobody to ask, so it refuses.
+func confirm(servers []api.CloudServer, action string, byName bool) error {
+	if assumeYes || viper.GetBool("dry_run") {
+		// a dry run changes nothing, so there is nothing to confirm
+		return nil
+	}
+	if !ui.IsTerminal(os.Stdin) {
+		return errors.New("confirmation required but stdin is not a terminal; pass --yes to confirm")
+	}
+
+	var (
+		confirmed bool
+		err       error
+	)
+	if byName && len(servers) == 1 {
+		confirmed, err = vpsui.ConfirmByName(servers[0], action)
+	} else {
+		confirmed, err = vpsui.ConfirmAction(servers, action)
+	}
+	if errors.Is(err, huh.ErrUserAborted) {
+		return errNotConfirmed
+	}
+	if err != nil {
+		return err
+	}
+	if !confirmed && byName {
+		return fmt.Errorf("the server name did not match; %w", errNotConfirmed)
+	}
+	if !confirmed {
+		return errNotConfirmed
+	}
+	return nil
+}
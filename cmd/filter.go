@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/edvin/oh/ui"
+	"net"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// filterHelp documents the expressions understood by --filter
+const filterHelp = `Filter on the JSON fields of the results, nested with dots, e.g.
+  status=active            equal, ignoring case (!= for not equal)
+  image.osDistro~ubuntu    matches a regular expression, ignoring case (!~ for no match)
+  minRAM>=2048             compares numbers, or text such as dates (<, <=, >, >=)
+  ipv4 in 10.0.0.0/8       in a network or a list, e.g. status in (active,stopped) (not in)
+Conditions separated by commas, and repeated --filter flags, must all match.`
+
+var (
+	filterPattern   = regexp.MustCompile(`^([\w.]+)\s*(!=|!~|>=|<=|=|~|>|<)\s*(.*)$`)
+	filterInPattern = regexp.MustCompile(`^([\w.]+)\s+(?i)(in|not\s+in)\s+(.*)$`)
+)
+
+// condition is a single field comparison of a --filter expression
+type condition struct {
+	field  string
+	op     string
+	value  string
+	negate bool
+	re     *regexp.Regexp
+	// in holds the values of in, nets the networks among them
+	in   []string
+	nets []*net.IPNet
+}
+
+// filterItems returns the items matching every expression in exprs
+func filterItems[T any](items []T, exprs []string) ([]T, error) {
+	conds, err := parseFilterFor[T](exprs)
+	if err != nil || len(conds) == 0 {
+		return items, err
+	}
+
+	var matches []T
+	for _, item := range items {
+		fields, err := jsonFields(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to filter: %w", err)
+		}
+		if matchAll(conds, fields) {
+			matches = append(matches, item)
+		}
+	}
+	return matches, nil
+}
+
+// parseFilterFor parses expressions like parseFilter, and checks them against the
+// JSON fields of T, so that a misspelled field is an error
+func parseFilterFor[T any](exprs []string) ([]condition, error) {
+	conds, err := parseFilter(exprs)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range conds {
+		if err := checkField(reflect.TypeFor[T](), c.field); err != nil {
+			return nil, err
+		}
+	}
+	return conds, nil
+}
+
+// isFilter reports whether s is a filter expression rather than a name or glob
+func isFilter(s string) bool {
+	for _, part := range splitConditions(s) {
+		part = strings.TrimSpace(part)
+		if filterPattern.MatchString(part) || filterInPattern.MatchString(part) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFilter parses --filter expressions into the conditions that must all match
+func parseFilter(exprs []string) ([]condition, error) {
+	var conds []condition
+	for _, expr := range exprs {
+		for _, part := range splitConditions(expr) {
+			if strings.TrimSpace(part) == "" {
+				continue
+			}
+			c, err := parseCondition(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, c)
+		}
+	}
+	return conds, nil
+}
+
+// splitConditions splits at the commas that are not within parentheses
+func splitConditions(expr string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range expr {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, expr[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+func parseCondition(s string) (condition, error) {
+	m := filterPattern.FindStringSubmatch(s)
+	if m == nil {
+		m = filterInPattern.FindStringSubmatch(s)
+	}
+	if m == nil {
+		return condition{}, fmt.Errorf("invalid filter %q: expected field, operator and value, e.g. status=active", s)
+	}
+
+	c := condition{field: m[1], op: strings.Join(strings.Fields(strings.ToLower(m[2])), " "), value: unquote(m[3])}
+	switch c.op {
+	case "!=":
+		c.op, c.negate = "=", true
+	case "!~":
+		c.op, c.negate = "~", true
+	case "not in":
+		c.op, c.negate = "in", true
+	}
+
+	switch c.op {
+	case "~":
+		re, err := regexp.Compile("(?i)" + c.value)
+		if err != nil {
+			return condition{}, fmt.Errorf("invalid filter %q: %w", s, err)
+		}
+		c.re = re
+	case "in":
+		list := strings.TrimSpace(c.value)
+		if strings.HasPrefix(list, "(") && strings.HasSuffix(list, ")") {
+			list = list[1 : len(list)-1]
+		}
+		for _, v := range strings.Split(list, ",") {
+			v = unquote(v)
+			if _, ipNet, err := net.ParseCIDR(v); err == nil {
+				c.nets = append(c.nets, ipNet)
+			} else {
+				c.in = append(c.in, v)
+			}
+		}
+	}
+	return c, nil
+}
+
+// unquote trims spaces and a pair of quotes around a value
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func matchAll(conds []condition, fields map[string]any) bool {
+	for _, c := range conds {
+		v, found := lookupField(fields, c.field)
+		if (found && c.match(v)) == c.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether v satisfies the condition, ignoring negate. A list matches
+// if any of its elements does.
+func (c condition) match(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case []any:
+		return slices.ContainsFunc(v, c.match)
+	case map[string]any:
+		return false
+	}
+
+	s := fmt.Sprint(v)
+	switch c.op {
+	case "=":
+		if x, y, ok := numbers(s, c.value); ok {
+			return x == y
+		}
+		return strings.EqualFold(s, c.value)
+	case "~":
+		return c.re.MatchString(s)
+	case "in":
+		if ip := net.ParseIP(s); ip != nil && slices.ContainsFunc(c.nets, func(n *net.IPNet) bool { return n.Contains(ip) }) {
+			return true
+		}
+		return slices.ContainsFunc(c.in, func(e string) bool { return strings.EqualFold(s, e) })
+	}
+
+	cmp := ui.CompareValues(s, c.value)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func numbers(a, b string) (float64, float64, bool) {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	return x, y, errA == nil && errB == nil
+}
+
+var jsonMarshaler = reflect.TypeFor[json.Marshaler]()
+
+// checkField fails if path is not a dotted path of JSON field names of t
+func checkField(t reflect.Type, path string) error {
+	for _, part := range strings.Split(path, ".") {
+		for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Map || t.Kind() == reflect.Interface {
+			// anything goes below a map
+			return nil
+		}
+		fields := jsonFieldTypes(t)
+		next, ok := fields[strings.ToLower(part)]
+		if !ok {
+			names := make([]string, 0, len(fields))
+			for _, f := range fields {
+				names = append(names, f.name)
+			}
+			slices.Sort(names)
+			if len(names) == 0 {
+				return fmt.Errorf("unknown field %q in filter, it has no fields below it", path)
+			}
+			return fmt.Errorf("unknown field %q in filter, use one of %s", path, strings.Join(names, ", "))
+		}
+		t = next.typ
+	}
+	return nil
+}
+
+type jsonField struct {
+	name string
+	typ  reflect.Type
+}
+
+// jsonFieldTypes returns the fields of a struct as encoding/json names them, by their
+// lower case name. Types that encode themselves, such as api.Date, have no fields.
+func jsonFieldTypes(t reflect.Type) map[string]jsonField {
+	fields := map[string]jsonField{}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t.Implements(jsonMarshaler) {
+		return fields
+	}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			for k, v := range jsonFieldTypes(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[strings.ToLower(name)] = jsonField{name: name, typ: f.Type}
+	}
+	return fields
+}
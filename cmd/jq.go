@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/edvin/oh/ui"
+	"github.com/itchyny/gojq"
+	"github.com/spf13/cobra"
+	"io"
+	"strings"
+)
+
+var (
+	jqRawOutput bool
+	jqCompact   bool
+	jqArgs      []string
+	jqArgsJSON  []string
+)
+
+// runJQ applies a jq filter to the JSON encoding of v and writes each result,
+// the way the jq program does, but without needing it installed
+func runJQ(w io.Writer, filter string, v any) error {
+	query, err := gojq.Parse(filter)
+	if err != nil {
+		return jqParseError(filter, err)
+	}
+
+	names, values, err := jqVariables()
+	if err != nil {
+		return err
+	}
+	code, err := gojq.Compile(query, gojq.WithVariables(names))
+	if err != nil {
+		return fmt.Errorf("invalid jq filter %q: %w", filter, err)
+	}
+
+	// gojq works on what encoding/json decodes into, not on the api structs
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON for jq: %w", err)
+	}
+	var input any
+	if err := json.Unmarshal(b, &input); err != nil {
+		return fmt.Errorf("failed to marshal JSON for jq: %w", err)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if !jqCompact {
+		enc.SetIndent("", "  ")
+	}
+
+	iter := code.Run(input, values...)
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := result.(error); ok {
+			var halt *gojq.HaltError
+			if errors.As(err, &halt) && halt.Value() == nil {
+				return nil
+			}
+			return fmt.Errorf("jq: %w", err)
+		}
+		if s, ok := result.(string); ok && jqRawOutput {
+			if _, err := fmt.Fprintln(w, s); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := enc.Encode(result); err != nil {
+			return fmt.Errorf("jq: cannot encode %v: %w", result, err)
+		}
+	}
+}
+
+// jqParseError points at the position in the filter where parsing failed
+func jqParseError(filter string, err error) error {
+	var perr *gojq.ParseError
+	if !errors.As(err, &perr) {
+		return fmt.Errorf("invalid jq filter: %w", err)
+	}
+	// Offset is just past the offending token
+	pos := max(perr.Offset-len(perr.Token), 0)
+	line := strings.Count(filter[:pos], "\n")
+	lineStart := strings.LastIndex(filter[:pos], "\n") + 1
+	text, _, _ := strings.Cut(filter[lineStart:], "\n")
+	return fmt.Errorf("invalid jq filter: %v at line %d, column %d\n  %s\n  %s^",
+		err, line+1, pos-lineStart+1, text, strings.Repeat(" ", pos-lineStart))
+}
+
+// jqVariables returns the names and values of the --arg and --argjson variables
+func jqVariables() ([]string, []any, error) {
+	var names []string
+	var values []any
+	for _, kv := range jqArgs {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return nil, nil, fmt.Errorf("invalid --arg %q; expected name=value", kv)
+		}
+		names, values = append(names, "$"+name), append(values, value)
+	}
+	for _, kv := range jqArgsJSON {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			return nil, nil, fmt.Errorf("invalid --argjson %q; expected name=json", kv)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return nil, nil, fmt.Errorf("invalid --argjson %s: %w", name, err)
+		}
+		names, values = append(names, "$"+name), append(values, v)
+	}
+	return names, values, nil
+}
+
+// validateJQFlags fails when the jq options are given without a jq filter
+func validateJQFlags(cmd *cobra.Command) error {
+	name, _, _ := ui.ParseFormat(outputFormat(cmd))
+	if name == "jq" {
+		return nil
+	}
+	for _, f := range []string{"raw-output", "compact", "arg", "argjson"} {
+		if cmd.Flags().Changed(f) {
+			return fmt.Errorf("--%s only applies to --jq", f)
+		}
+	}
+	return nil
+}
+
+func init() {
+	// jq=FILTER backs --jq
+	ui.RegisterFormatter("jq", false, true, func(w io.Writer, filter string, out ui.Output) error {
+		return runJQ(w, filter, out.Value)
+	})
+
+	rootCmd.PersistentFlags().BoolVarP(&jqRawOutput, "raw-output", "r", false, "With --jq, print strings without quotes")
+	rootCmd.PersistentFlags().BoolVarP(&jqCompact, "compact", "c", false, "With --jq, print each result on a single line")
+	rootCmd.PersistentFlags().StringArrayVar(&jqArgs, "arg", nil, "With --jq, set $name to a string, as name=value (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&jqArgsJSON, "argjson", nil, "With --jq, set $name to a JSON value, as name=json (repeatable)")
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNetworkChangesDropCachedNetworks(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	cached := func() bool {
+		r := env.mustRun("cache", "list", "-o", "csv")
+		return strings.Contains(r.stdout, "virtual_networks")
+	}
+
+	changes := [][]string{
+		{"vps", "network", "attach", "web-01", "--network-id", "net-a", "--ipv4", "10.0.0.20"},
+		{"vps", "network", "detach", "web-01", "--network-id", "net-a", "--yes"},
+	}
+	for _, change := range changes {
+		env.mustRun("vps", "network", "list-available")
+		if !cached() {
+			t.Fatal("list-available did not cache the networks")
+		}
+		env.mustRun(change...)
+		if cached() {
+			t.Errorf("oh %s kept the cached networks with their free addresses", strings.Join(change, " "))
+		}
+	}
+}
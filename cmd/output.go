@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/edvin/oh/config"
+	"github.com/edvin/oh/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+var (
+	outputSpec  string
+	listColumns []string
+	listSortBy  []string
+	listFilters []string
+	savePreset  bool
+)
+
+// outputFormat returns the format selected with -o, or with its --json and --jq aliases
+func outputFormat(cmd *cobra.Command) string {
+	switch {
+	case cmd.Flags().Changed("jq"):
+		return "jq=" + jqFilter
+	case jsonOutput:
+		return "json"
+	default:
+		return outputSpec
+	}
+}
+
+// tableOutput reports whether the output is a table for people rather than data for scripts
+func tableOutput(cmd *cobra.Command) bool {
+	name, _, _ := ui.ParseFormat(outputFormat(cmd))
+	return name == "table" || name == "wide"
+}
+
+// printList writes items in the output format, cols drive the tabular formats.
+// --filter picks the items, --columns and --sort-by, or the preset of the command,
+// pick and order the columns.
+func printList[T any](cmd *cobra.Command, items []T, cols ...ui.TableColumn[T]) error {
+	columns, sortBy := tablePreset(cmd)
+
+	items, err := filterItems(items, listFilters)
+	if err != nil {
+		return err
+	}
+	if len(sortBy) > 0 {
+		if items, err = ui.SortBy(items, cols, sortBy); err != nil {
+			return err
+		}
+	}
+	if len(columns) > 0 {
+		if cols, err = ui.SelectColumns(cols, columns); err != nil {
+			return err
+		}
+	}
+	if savePreset {
+		if err := saveTablePreset(cmd); err != nil {
+			return err
+		}
+	}
+	return ui.Format(os.Stdout, outputFormat(cmd), ui.List(items, cols...))
+}
+
+// printItem writes a single item in the output format, as key: value lines in a table
+func printItem[T any](cmd *cobra.Command, item T, cols ...ui.TableColumn[T]) error {
+	return ui.Format(os.Stdout, outputFormat(cmd), ui.Item(item, cols...))
+}
+
+// printValue writes v in a structured output format such as json or yaml.
+// Returns (true, err) if it did, or (false, nil) for a table, which the caller prints.
+func printValue(v any, cmd *cobra.Command) (bool, error) {
+	if tableOutput(cmd) {
+		return false, nil
+	}
+	return true, ui.Format(os.Stdout, outputFormat(cmd), ui.Output{Value: v})
+}
+
+// validateOutputFlags fails early on an unknown -o, before any request is made
+func validateOutputFlags(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("output") && (jsonOutput || cmd.Flags().Changed("jq")) {
+		return fmt.Errorf("--json and --jq are aliases of -o, use only one of them")
+	}
+	if _, _, err := ui.ParseFormat(outputFormat(cmd)); err != nil {
+		return err
+	}
+	return validateJQFlags(cmd)
+}
+
+// countOf starts the summary of a nested list, e.g. "2 plans: "
+func countOf(n int, noun string) string {
+	switch n {
+	case 0:
+		return "no " + noun + "s"
+	case 1:
+		return "1 " + noun + ": "
+	}
+	return fmt.Sprintf("%d %ss: ", n, noun)
+}
+
+func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return ui.Formats(), cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// addListFlags adds --filter, --columns, --sort-by and --save-preset to a list command,
+// keys are the names of its columns for completion
+func addListFlags(cmd *cobra.Command, keys []string) {
+	cmd.Flags().StringArrayVar(&listFilters, "filter", nil, "Show only what matches, e.g. status=active (repeatable, see --help)")
+	cmd.Long += "\n\n" + filterHelp
+	cmd.Flags().StringSliceVar(&listColumns, "columns", nil, "Columns to show, e.g. id,name,status (default from the preset)")
+	cmd.Flags().StringSliceVar(&listSortBy, "sort-by", nil, "Columns to sort by, - for descending, e.g. name,-id")
+	cmd.Flags().BoolVar(&savePreset, "save-preset", false, "Save --columns and --sort-by as the default of this command")
+
+	complete := func(desc bool) cobra.CompletionFunc {
+		return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			// complete the last of a comma separated list
+			done, _ := strings.CutSuffix(toComplete, toComplete[strings.LastIndex(toComplete, ",")+1:])
+			var comps []string
+			for _, k := range keys {
+				comps = append(comps, done+k)
+				if desc {
+					comps = append(comps, done+"-"+k)
+				}
+			}
+			return comps, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+		}
+	}
+	_ = cmd.RegisterFlagCompletionFunc("columns", complete(false))
+	_ = cmd.RegisterFlagCompletionFunc("sort-by", complete(true))
+}
+
+// presetKey is the key of the command's preset under tables in the config, e.g. vps_list
+func presetKey(cmd *cobra.Command) string {
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	return strings.ReplaceAll(path, " ", "_")
+}
+
+// tablePreset returns --columns and --sort-by if given, and the saved preset otherwise
+func tablePreset(cmd *cobra.Command) ([]string, []string) {
+	columns, sortBy := listColumns, listSortBy
+	if !cmd.Flags().Changed("columns") {
+		columns = presetList("tables." + presetKey(cmd) + ".columns")
+	}
+	if !cmd.Flags().Changed("sort-by") {
+		sortBy = presetList("tables." + presetKey(cmd) + ".sort_by")
+	}
+	return columns, sortBy
+}
+
+// presetList reads a setting written either as a YAML list or as a comma separated string
+func presetList(key string) []string {
+	switch v := viper.Get(key).(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	case []any:
+		list := make([]string, len(v))
+		for i, e := range v {
+			list[i] = fmt.Sprint(e)
+		}
+		return list
+	}
+	return nil
+}
+
+func saveTablePreset(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("columns") && !cmd.Flags().Changed("sort-by") {
+		return fmt.Errorf("--save-preset needs --columns or --sort-by")
+	}
+	key := presetKey(cmd)
+	err := config.Update(func(settings map[string]any) error {
+		tables, _ := settings["tables"].(map[string]any)
+		if tables == nil {
+			tables = map[string]any{}
+			settings["tables"] = tables
+		}
+		preset, _ := tables[key].(map[string]any)
+		if preset == nil {
+			preset = map[string]any{}
+			tables[key] = preset
+		}
+		if cmd.Flags().Changed("columns") {
+			preset["columns"] = strings.Join(listColumns, ",")
+		}
+		if cmd.Flags().Changed("sort-by") {
+			preset["sort_by"] = strings.Join(listSortBy, ",")
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save the preset: %w", err)
+	}
+	cmd.Printf("💾 Saved as the default of '%s'\n", cmd.CommandPath())
+	return nil
+}
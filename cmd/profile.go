@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/edvin/oh/config"
+	"github.com/edvin/oh/credentials"
+	"github.com/edvin/oh/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"strings"
+)
+
+var (
+	profileBaseURL string
+	profileToken   string
+	profileStore   string
+	profileUse     bool
+)
+
+type profileInfo struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+	BaseURL string `json:"baseUrl"`
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage profiles for multiple accounts and environments",
+	Long: `Profiles keep separate tokens, base URLs and other settings for several accounts or API environments.
+
+The top-level settings in the config file form the "default" profile. Named profiles live under "profiles" in the config file:
+
+  current_profile: customer-a
+  profiles:
+    customer-a:
+      token_ref: keyring:customer-a
+    staging:
+      base_url: https://staging.example.com/api/v1/
+      token_ref: file:/home/me/.config/oh/staging.token
+
+Select a profile for a single command with --profile or the OH_PROFILE environment variable.`,
+}
+
+var addProfileCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add a profile",
+	Example: `  # add a profile and store its token in the OS keyring
+  oh profile add customer-a --token abc123
+
+  # add a profile for another API environment and switch to it
+  oh profile add staging --base-url https://staging.example.com/api/v1/ --use
+
+  # store the token later
+  oh --profile staging token`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: NoArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		settings := map[string]any{}
+		if profileBaseURL != "" {
+			settings["base_url"] = profileBaseURL
+		}
+
+		if err := config.AddProfile(name, settings); err != nil {
+			return err
+		}
+		cmd.Printf("👤 Profile %q added\n", name)
+
+		if profileToken != "" {
+			// stored as by oh --profile <name> token, in a credential store of the new profile
+			viper.Set("profile", name)
+			if err := config.ApplyProfile(); err != nil {
+				return err
+			}
+			if err := storeToken(cmd, profileStore, profileToken); err != nil {
+				return fmt.Errorf("profile %q was added, but its token was not stored: %w", name, err)
+			}
+			cmd.Printf("🔐 Token of profile %q stored\n", name)
+		}
+
+		if profileUse {
+			if err := config.UseProfile(name); err != nil {
+				return err
+			}
+			cmd.Printf("👤 Switched to profile %q\n", name)
+		}
+		return nil
+	},
+}
+
+var useProfileCmd = &cobra.Command{
+	Use:               "use <name>",
+	Short:             "Switch the current profile",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.UseProfile(args[0]); err != nil {
+			return err
+		}
+		cmd.Printf("👤 Switched to profile %q\n", args[0])
+		return nil
+	},
+}
+
+var listProfilesCmd = &cobra.Command{
+	Use:               "list",
+	Short:             "List profiles",
+	ValidArgsFunction: NoArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		names, err := config.Profiles()
+		if err != nil {
+			return err
+		}
+
+		// profiles without a base_url inherit the top-level one
+		defaults, _, err := config.ProfileSettings(config.DefaultProfile)
+		if err != nil {
+			return err
+		}
+		inherited, _ := defaults["base_url"].(string)
+		if inherited == "" {
+			inherited = defaultBaseURL
+		}
+
+		current := config.Profile()
+		profiles := make([]profileInfo, 0, len(names))
+		for _, name := range names {
+			settings, _, err := config.ProfileSettings(name)
+			if err != nil {
+				return err
+			}
+			baseURL, _ := settings["base_url"].(string)
+			if baseURL == "" {
+				baseURL = inherited
+			}
+			profiles = append(profiles, profileInfo{Name: name, Current: name == current, BaseURL: baseURL})
+		}
+
+		return printList(cmd, profiles, profileColumns()...)
+	},
+}
+
+var removeProfileCmd = &cobra.Command{
+	Use:               "remove <name>",
+	Short:             "Remove a profile and its stored settings",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeProfileNames,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.RemoveProfile(args[0]); err != nil {
+			return err
+		}
+		cmd.Printf("👤 Profile %q removed\n", args[0])
+		return nil
+	},
+}
+
+var currentProfileCmd = &cobra.Command{
+	Use:               "current",
+	Short:             "Print the active profile",
+	ValidArgsFunction: NoArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		current := config.Profile()
+
+		if printed, err := printValue(current, cmd); printed {
+			return err
+		}
+
+		fmt.Println(current)
+		return nil
+	},
+}
+
+func init() {
+	addProfileCmd.Flags().StringVar(&profileBaseURL, "base-url", "", "API base URL for the profile (default is the top-level base_url)")
+	addProfileCmd.Flags().StringVar(&profileToken, "token", "", "API token for the profile, stored like 'oh token' does")
+	addProfileCmd.Flags().StringVar(&profileStore, "store", "",
+		fmt.Sprintf("where to store the token, one of [%s] (default keyring, falling back to file)", strings.Join(credentials.Kinds, ", ")))
+	addProfileCmd.RegisterFlagCompletionFunc("store", cobra.FixedCompletions(credentials.Kinds, cobra.ShellCompDirectiveNoFileComp))
+	addProfileCmd.Flags().BoolVar(&profileUse, "use", false, "Switch to the new profile")
+
+	addListFlags(listProfilesCmd, ui.ColumnKeys(profileColumns()))
+	profileCmd.AddCommand(addProfileCmd, useProfileCmd, listProfilesCmd, removeProfileCmd, currentProfileCmd)
+	rootCmd.AddCommand(profileCmd)
+}
+
+func profileColumns() []ui.TableColumn[profileInfo] {
+	return []ui.TableColumn[profileInfo]{
+		ui.Column("Current", func(p profileInfo) string {
+			if p.Current {
+				return "*"
+			}
+			return ""
+		}),
+		ui.Column("Name", func(p profileInfo) string { return p.Name }),
+		ui.Column("Base URL", func(p profileInfo) string { return p.BaseURL }),
+	}
+}
+
+// isProfileCommand reports whether cmd is part of the profile command group
+func isProfileCommand(cmd *cobra.Command) bool {
+	for c := cmd; c != nil; c = c.Parent() {
+		if c == profileCmd {
+			return true
+		}
+	}
+	return false
+}
+
+func completeProfileNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	names, err := config.Profiles()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var comps []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			comps = append(comps, name)
+		}
+	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
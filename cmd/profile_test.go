@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/zalando/go-keyring"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// readConfig returns the config file as written by the commands
+func (e *testEnv) readConfig() string {
+	e.t.Helper()
+	b, err := os.ReadFile(e.config)
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestProfiles(t *testing.T) {
+	env := newTestEnv(t, nil)
+	list := func() []profileInfo {
+		t.Helper()
+		r := env.mustRun("profile", "list", "-o", "json")
+		var profiles []profileInfo
+		if err := json.Unmarshal([]byte(r.stdout), &profiles); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+		}
+		return profiles
+	}
+
+	if got, want := list(), []profileInfo{{"default", true, defaultBaseURL}}; !slices.Equal(got, want) {
+		t.Errorf("profiles without a config: %+v, want %+v", got, want)
+	}
+
+	env.writeConfig("base_url: https://api.example.com/v1/\n")
+	env.mustRun("profile", "add", "customer-a")
+	r := env.mustRun("profile", "add", "staging", "--base-url", "https://staging.example.com/api/v1/", "--use")
+	if !strings.Contains(r.stderr, `Profile "staging" added`) || !strings.Contains(r.stderr, `Switched to profile "staging"`) {
+		t.Errorf("profile add --use: stderr %q", r.stderr)
+	}
+	if c := env.readConfig(); !strings.Contains(c, "current_profile: staging") {
+		t.Errorf("profile add --use did not switch:\n%s", c)
+	}
+
+	// customer-a inherits the top-level base_url
+	want := []profileInfo{
+		{"default", false, "https://api.example.com/v1/"},
+		{"customer-a", false, "https://api.example.com/v1/"},
+		{"staging", true, "https://staging.example.com/api/v1/"},
+	}
+	if got := list(); !slices.Equal(got, want) {
+		t.Errorf("profiles %+v, want %+v", got, want)
+	}
+
+	env.mustRun("profile", "use", "customer-a")
+	if r := env.mustRun("profile", "current"); r.stdout != "customer-a\n" {
+		t.Errorf("current profile after use: %q", r.stdout)
+	}
+
+	// removing the current profile goes back to the default one
+	env.mustRun("profile", "remove", "customer-a")
+	if c := env.readConfig(); strings.Contains(c, "customer-a") || strings.Contains(c, "current_profile") {
+		t.Errorf("config after removing the current profile:\n%s", c)
+	}
+	if r := env.mustRun("profile", "current"); r.stdout != "default\n" {
+		t.Errorf("current profile after remove: %q", r.stdout)
+	}
+	env.mustRun("profile", "remove", "staging")
+	if c := env.readConfig(); c != "base_url: https://api.example.com/v1/\n" {
+		t.Errorf("config after removing every profile:\n%s", c)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"add", "default"}, `"default" is reserved for the top-level settings`},
+		{[]string{"add", "Customer A"}, `invalid profile name "Customer A"`},
+		{[]string{"use", "missing"}, `profile "missing" does not exist`},
+		{[]string{"remove", "missing"}, `profile "missing" does not exist`},
+		{[]string{"remove", "default"}, "the default profile cannot be removed"},
+	}
+	for _, tt := range tests {
+		if r := env.run(append([]string{"profile"}, tt.args...)...); r.code != 1 || !strings.Contains(r.stderr, tt.want) {
+			t.Errorf("profile %v: exit %d, stderr %q, want %q", tt.args, r.code, r.stderr, tt.want)
+		}
+	}
+	env.mustRun("profile", "add", "customer-a")
+	if r := env.run("profile", "add", "customer-a"); r.code != 1 || !strings.Contains(r.stderr, `profile "customer-a" already exists`) {
+		t.Errorf("adding a profile twice: exit %d, stderr %q", r.code, r.stderr)
+	}
+}
+
+func TestProfileAddToken(t *testing.T) {
+	env := newTestEnv(t, nil)
+	t.Setenv("OH_PASSPHRASE", "")
+	t.Cleanup(keyring.MockInit)
+	keyring.MockInit()
+
+	env.mustRun("profile", "add", "customer-a", "--token", "abc123")
+	if c := env.readConfig(); !strings.Contains(c, "token_ref: keyring:customer-a") || strings.Contains(c, "abc123") {
+		t.Errorf("token of the profile not stored in the keyring:\n%s", c)
+	}
+	if token, err := keyring.Get("oh", "customer-a"); err != nil || token != "abc123" {
+		t.Errorf("keyring holds %q, %v", token, err)
+	}
+
+	env.mustRun("profile", "add", "staging", "--token", "def456", "--store", "plain")
+	if c := env.readConfig(); !strings.Contains(c, "token: def456") {
+		t.Errorf("token of the profile not stored in plaintext:\n%s", c)
+	}
+
+	// the default profile is left alone
+	if r := env.mustRun("profile", "current"); r.stdout != "default\n" {
+		t.Errorf("current profile is %q", r.stdout)
+	}
+
+	// a token that cannot be stored is not left in the config file either
+	keyring.MockInitWithError(errors.New("no keyring"))
+	r := env.run("profile", "add", "customer-b", "--token", "ghi789")
+	if r.code != 1 || !strings.Contains(r.stderr, `profile "customer-b" was added, but its token was not stored`) {
+		t.Errorf("no keyring and no passphrase: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if c := env.readConfig(); !strings.Contains(c, "customer-b:") || strings.Contains(c, "ghi789") {
+		t.Errorf("config after the failed token:\n%s", c)
+	}
+}
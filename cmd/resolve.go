@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/cache"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// serverRefHelp documents the server references understood by resolveServers
+const serverRefHelp = `A VPS can be referenced by its ID, its exact name, a glob on the name (web-*) or a
+filter, in the same expressions as --filter:
+
+` + filterHelp
+
+// resolveServers returns the servers matching ref, which is an ID, an exact name,
+// a glob on the name or a filter expression as taken by --filter.
+// The cached server list is used, and refreshed once if nothing matches.
+func resolveServers(ctx context.Context, ref string) ([]api.CloudServer, error) {
+	match, err := serverMatcher(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	servers, err := cache.Call(cache.KeyCloudServers, cache.DefaultTTL, func() ([]api.CloudServer, error) {
+		return apiClient().ListCloudServers(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := filterServers(servers, match)
+	if len(matches) == 0 {
+		// The cache may predate the server, look again
+		if servers, err = apiClient().ListCloudServers(ctx); err != nil {
+			return nil, err
+		}
+		matches = filterServers(servers, match)
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no VPS matches %q", ref)
+	}
+	return matches, nil
+}
+
+// resolveServerId resolves ref to exactly one server. Numeric IDs are used as-is,
+// without looking up the server list.
+func resolveServerId(ctx context.Context, ref string) (int, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return id, nil
+	}
+
+	matches, err := resolveServers(ctx, ref)
+	if err != nil {
+		return 0, err
+	}
+	if len(matches) > 1 {
+		candidates := make([]string, len(matches))
+		for i, s := range matches {
+			candidates[i] = fmt.Sprintf("  %d\t%s", s.Id, s.Name)
+		}
+		return 0, fmt.Errorf("%q is ambiguous, it matches %d servers:\n%s\nuse the ID or a more specific reference",
+			ref, len(matches), strings.Join(candidates, "\n"))
+	}
+	return matches[0].Id, nil
+}
+
+func filterServers(servers []api.CloudServer, match func(api.CloudServer) bool) []api.CloudServer {
+	var matches []api.CloudServer
+	for _, s := range servers {
+		if match(s) {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// serverMatcher parses a server reference into a predicate
+func serverMatcher(ref string) (func(api.CloudServer) bool, error) {
+	switch {
+	case ref == "":
+		return nil, fmt.Errorf("empty VPS reference")
+
+	case isNumeric(ref):
+		id, err := strconv.Atoi(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VPS ID %q: %w", ref, err)
+		}
+		return func(s api.CloudServer) bool { return s.Id == id }, nil
+
+	case isFilter(ref):
+		conds, err := parseFilterFor[api.CloudServer]([]string{ref})
+		if err != nil {
+			return nil, err
+		}
+		return func(s api.CloudServer) bool {
+			fields, err := jsonFields(s)
+			return err == nil && matchAll(conds, fields)
+		}, nil
+
+	case strings.ContainsAny(ref, "*?["):
+		if _, err := path.Match(ref, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", ref, err)
+		}
+		return func(s api.CloudServer) bool {
+			ok, _ := path.Match(ref, s.Name)
+			return ok
+		}, nil
+
+	default:
+		return func(s api.CloudServer) bool { return s.Name == ref }, nil
+	}
+}
+
+// jsonFields converts v to the generic form of its JSON encoding
+func jsonFields(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	// Keep numbers as written, float64 would print large IDs in exponent form
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var fields map[string]any
+	err = dec.Decode(&fields)
+	return fields, err
+}
+
+// lookupField walks a dotted path of JSON field names, matching names case-insensitively
+func lookupField(fields map[string]any, name string) (any, bool) {
+	var current any = fields
+	for _, part := range strings.Split(name, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		next, found := m[part]
+		if !found {
+			for k, v := range m {
+				if strings.EqualFold(k, part) {
+					next, found = v, true
+					break
+				}
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+func isNumeric(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// resolveServerList resolves several references, or every server if all is set,
+// into a list without duplicates in the order they were referenced
+func resolveServerList(ctx context.Context, refs []string, all bool) ([]api.CloudServer, error) {
+	if all {
+		servers, err := apiClient().ListCloudServers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(servers) == 0 {
+			return nil, fmt.Errorf("there are no servers")
+		}
+		return servers, nil
+	}
+
+	var servers []api.CloudServer
+	seen := map[int]bool{}
+	for _, ref := range refs {
+		matches, err := resolveServers(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range matches {
+			if !seen[s.Id] {
+				seen[s.Id] = true
+				servers = append(servers, s)
+			}
+		}
+	}
+	return servers, nil
+}
+
+// isServerPattern reports whether ref may match more than one server
+func isServerPattern(ref string) bool {
+	return isFilter(ref) || strings.ContainsAny(ref, "*?[")
+}
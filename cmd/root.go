@@ -1,68 +1,69 @@
 package cmd
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"context"
+	"errors"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/config"
+	"github.com/edvin/oh/ui"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"os"
-	"os/exec"
+	"os/signal"
 )
 
+// defaultBaseURL is the base_url of profiles that set none, and of the config file
+const defaultBaseURL = "https://onehome.dogado.de/api/v1/"
+
 var (
 	cfgFile    string
 	jsonOutput bool
 	jqFilter   string
+	profileErr error
 )
 
-// PrintJSON writes v in JSON/jq mode if requested.
-// Returns (true, err) if JSON/jq output was performed (and err is non-nil on failure),
-// or (false, nil) to fall back to TUI.
-func PrintJSON(v any, cmd *cobra.Command) (bool, error) {
-	// if --jq was passed, ALWAYS shell out to jq
-	if f := cmd.Flags().Lookup("jq"); f != nil && f.Changed {
-		data, err := json.Marshal(v)
-		if err != nil {
-			return true, fmt.Errorf("failed to marshal JSON for jq: %w", err)
-		}
-		jqCmd := exec.Command("jq", jqFilter)
-		jqCmd.Stdin = bytes.NewReader(data)
-		jqCmd.Stdout = os.Stdout
-		jqCmd.Stderr = os.Stderr
-		if err := jqCmd.Run(); err != nil {
-			return true, fmt.Errorf("jq failed: %w", err)
-		}
-		return true, nil
-	}
-
-	// else if --json, use Go’s JSON
-	if jsonOutput {
-		out, err := json.MarshalIndent(v, "", "  ")
-		if err != nil {
-			return true, fmt.Errorf("failed to JSON-encode output: %w", err)
-		}
-		fmt.Println(string(out))
-		return true, nil
-	}
-
-	// return false if output was not handled
-	return false, nil
-}
-
 var rootCmd = &cobra.Command{
 	Use:   "oh",
 	Short: "oneHome CLI Tool",
 	Long:  `Configure and control your oneHome resources, like Virtual Server instances from the command line.`,
+	// Errors are printed by Execute, which knows that a dry run is not a failure
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Profile management must keep working when the active profile is broken
+		if profileErr != nil && !isProfileCommand(cmd) {
+			return profileErr
+		}
+		return validateOutputFlags(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// In-flight API calls are cancelled when the process receives an interrupt (Ctrl-C).
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
+	if code := exitCode(err); code != 0 {
+		os.Exit(code)
+	}
+}
+
+// exitCode prints err and returns the exit status of the process for it
+func exitCode(err error) int {
+	if errors.Is(err, api.ErrDryRun) {
+		return 0
+	}
+	var status exitStatus
+	if errors.As(err, &status) {
+		return int(status)
+	}
 	if err != nil {
-		os.Exit(1)
+		rootCmd.PrintErrln(rootCmd.ErrPrefix(), err.Error())
+		return 1
 	}
+	return 0
 }
 
 func init() {
@@ -70,22 +71,73 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.oh.yaml)")
 
+	// Profile selection, also possible with OH_PROFILE
+	rootCmd.PersistentFlags().String("profile", "", "profile to use for this command (default is the current profile)")
+	_ = viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfileNames)
+
 	rootCmd.PersistentFlags().
-		BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+		StringVarP(&outputSpec, "output", "o", "table",
+			"output format: table, wide, json, yaml, csv, tsv, name, go-template=TEMPLATE or jsonpath=TEMPLATE")
+	rootCmd.RegisterFlagCompletionFunc("output", completeOutputFormats)
 
 	rootCmd.PersistentFlags().
-		StringVarP(&jqFilter, "jq", "j", "", "Pipe JSON through jq (optional FILTER). JSON output generated by jq")
+		BoolVar(&jsonOutput, "json", false, "Output in JSON format, same as -o json")
+
+	rootCmd.PersistentFlags().
+		StringVarP(&jqFilter, "jq", "j", "", "Filter JSON with a jq expression (optional FILTER), same as -o jq=FILTER")
 
 	// If user passes just `--jq` with no value, treat it as `--jq .`
 	f := rootCmd.PersistentFlags().Lookup("jq")
 	f.NoOptDefVal = "." // filter = "." when --jq is present without =
 	f.DefValue = ""     // filter = "" when --jq omitted
 
+	// Exact values instead of humanized sizes, relative dates and summaries
+	rootCmd.PersistentFlags().
+		BoolVar(&ui.Raw, "raw", false, "show exact values in tables instead of humanized sizes, dates and summaries (not jq's -r, that is --raw-output)")
+
 	// No-cache option
 	rootCmd.PersistentFlags().
 		Bool("no-cache", false, "disable on-disk caching of API responses")
 
 	_ = viper.BindPFlag("no-cache", rootCmd.PersistentFlags().Lookup("no-cache"))
+
+	// HTTP timeout per request
+	rootCmd.PersistentFlags().
+		Duration("request-timeout", api.DefaultTimeout, "timeout for each API request")
+
+	_ = viper.BindPFlag("request_timeout", rootCmd.PersistentFlags().Lookup("request-timeout"))
+
+	// Retries for failed requests, also configurable as retry.* in the config file
+	rootCmd.PersistentFlags().
+		Int("retries", api.DefaultRetryPolicy.MaxAttempts, "maximum number of attempts per API request (1 disables retries)")
+	rootCmd.PersistentFlags().
+		Duration("retry-budget", api.DefaultRetryPolicy.MaxElapsed, "total time budget for retrying an API request")
+
+	_ = viper.BindPFlag("retry.max_attempts", rootCmd.PersistentFlags().Lookup("retries"))
+	_ = viper.BindPFlag("retry.max_elapsed", rootCmd.PersistentFlags().Lookup("retry-budget"))
+
+	// Client-side rate limit, shared by all oh processes on this machine
+	rootCmd.PersistentFlags().
+		Float64("rate-limit", 10, "maximum API requests per second across all oh processes (0 disables)")
+
+	_ = viper.BindPFlag("rate_limit.rps", rootCmd.PersistentFlags().Lookup("rate-limit"))
+	viper.SetDefault("rate_limit.burst", 10)
+
+	// Diagnostics on stderr
+	rootCmd.PersistentFlags().
+		BoolP("verbose", "v", false, "log API requests, responses and retries to stderr")
+	rootCmd.PersistentFlags().
+		Bool("debug", false, "like --verbose, but also dump headers and bodies (secrets are redacted)")
+
+	_ = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	_ = viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+
+	// Print state-changing requests instead of sending them
+	rootCmd.PersistentFlags().
+		Bool("dry-run", false, "print POST/PUT/PATCH/DELETE requests instead of sending them (lookups still run)")
+
+	_ = viper.BindPFlag("dry_run", rootCmd.PersistentFlags().Lookup("dry-run"))
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -103,15 +155,22 @@ func initConfig() {
 		viper.SetConfigName(".oh")
 		viper.SetConfigType("yaml")
 
-		// Set defaults
-		viper.SetDefault("base_url", "https://onehome.dogado.de/api/v1/")
 	}
 
+	// Set defaults
+	viper.SetDefault("base_url", defaultBaseURL)
+	// The API does not take cloud-init user-data yet, see --user-data
+	viper.SetDefault("cloud_init.enabled", false)
+
 	// Read in environment variables that match
 	viper.AutomaticEnv()
+	_ = viper.BindEnv("profile", "OH_PROFILE")
 
 	// Read configuration file
 	_ = viper.ReadInConfig()
+
+	// Overlay the active profile, a missing profile is reported once the command runs
+	profileErr = config.ApplyProfile()
 }
 
 // Completion which will short-circuit file lookup from the shell
@@ -1,8 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"github.com/edvin/oh/api"
 	"github.com/edvin/oh/config"
+	"github.com/edvin/oh/credentials"
 	tokenui "github.com/edvin/oh/ui/token"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -11,6 +14,8 @@ import (
 	"strings"
 )
 
+var skipTokenVerify bool
+
 var tokenCmd = &cobra.Command{
 	Use:   "token",
 	Short: "Supply your credentials to login.",
@@ -23,7 +28,15 @@ var tokenCmd = &cobra.Command{
 
   # read from a file or pipe
   oh token < mytoken.txt
-  cat mytoken.txt | oh token`,
+  cat mytoken.txt | oh token
+
+  # encrypt the token with a passphrase, or hand it to a credential helper,
+  # instead of the OS keyring
+  oh token --store file
+  oh token --store helper
+
+  # keep the token in plaintext in the config file
+  oh token --store plain`,
 	SilenceUsage: true,
 	Args:         cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -52,16 +65,111 @@ var tokenCmd = &cobra.Command{
 			}
 		}
 
-		viper.Set("token", token)
-		if err := config.Save(); err != nil {
-			return fmt.Errorf("failed to save config: %w", err)
+		var customer api.Customer
+		if !skipTokenVerify {
+			c, err := verifyToken(cmd.Context(), token)
+			if err != nil {
+				return err
+			}
+			customer = c
 		}
 
-		cmd.Println("🔐 Token stored!")
+		if err := storeToken(cmd, viper.GetString("credential_store"), token); err != nil {
+			return err
+		}
+
+		if skipTokenVerify {
+			cmd.Println("🔐 Token stored!")
+		} else {
+			cmd.Printf("🔐 Token stored! Logged in as %s (%d)\n", customer.Name, customer.Id)
+		}
 		return nil
 	},
 }
 
+// verifyToken checks the token against the API before it is stored
+func verifyToken(ctx context.Context, token string) (api.Customer, error) {
+	c := *apiClient()
+	c.Tokens = api.StaticToken(token)
+	customer, err := c.GetCustomer(ctx)
+	if api.IsUnauthorized(err) {
+		return customer, fmt.Errorf("the token was rejected by the API, it was not stored: %w", err)
+	}
+	if err != nil {
+		return customer, fmt.Errorf("could not verify the token, use --no-verify to store it anyway: %w", err)
+	}
+	return customer, nil
+}
+
+// storeToken saves the token of the active profile in the credential store of the given
+// kind, or the keyring or encrypted file if kind is empty. The config file keeps the token
+// itself only for the plain store, otherwise a token_ref.
+func storeToken(cmd *cobra.Command, kind, token string) error {
+	if kind == credentials.KindPlain {
+		if err := config.Set("token", token); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+		return config.Unset("token_ref")
+	}
+
+	var (
+		store credentials.Store
+		err   error
+	)
+	if kind == "" {
+		store, err = saveSecurely(cmd, token)
+	} else {
+		store, err = saveToStore(kind, token)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := config.Set("token_ref", store.Ref()); err != nil {
+		return fmt.Errorf("failed to save config: %w", err)
+	}
+	// Don't leave a plaintext copy behind
+	return config.Unset("token")
+}
+
+// saveSecurely is used when no store was chosen: the OS keyring, or the encrypted token
+// file where there is no keyring. Plaintext is never the fallback.
+func saveSecurely(cmd *cobra.Command, token string) (credentials.Store, error) {
+	store, err := saveToStore(credentials.KindKeyring, token)
+	if err == nil {
+		return store, nil
+	}
+	cmd.Printf("🔐 The OS keyring is not available, encrypting the token with a passphrase instead: %v\n", err)
+	store, err = saveToStore(credentials.KindFile, token)
+	if err != nil {
+		return nil, fmt.Errorf("the token was not stored: there is no OS keyring and the encrypted token file failed: %w; "+
+			"pass --store plain to keep it in plaintext in the config file", err)
+	}
+	return store, nil
+}
+
+// saveToStore saves the token in a new store of the given kind
+func saveToStore(kind, token string) (credentials.Store, error) {
+	var arg string
+	switch kind {
+	case credentials.KindHelper:
+		arg = viper.GetString("credential_helper")
+	case credentials.KindFile:
+		arg = viper.GetString("credential_file")
+	}
+	store, err := credentials.New(kind, arg, credentialOptions())
+	if err != nil {
+		return nil, err
+	}
+	return store, store.Save(token)
+}
+
 func init() {
+	tokenCmd.Flags().BoolVar(&skipTokenVerify, "no-verify", false, "store the token without verifying it against the API")
+	tokenCmd.Flags().String("store", "",
+		fmt.Sprintf("where to store the token, one of [%s] (default from credential_store in the config, else keyring, falling back to file)", strings.Join(credentials.Kinds, ", ")))
+	tokenCmd.RegisterFlagCompletionFunc("store", cobra.FixedCompletions(credentials.Kinds, cobra.ShellCompDirectiveNoFileComp))
+	_ = viper.BindPFlag("credential_store", tokenCmd.Flags().Lookup("store"))
+
 	rootCmd.AddCommand(tokenCmd)
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"github.com/edvin/oh/api/apitest"
+	"github.com/zalando/go-keyring"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTokenStore(t *testing.T) {
+	env := newTestEnv(t, nil)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("OH_PASSPHRASE", "")
+	t.Cleanup(keyring.MockInit)
+
+	// the keyring is the default
+	keyring.MockInit()
+	env.mustRun("token", apitest.DefaultToken)
+	if c := env.readConfig(); !strings.Contains(c, "token_ref: keyring:default") || strings.Contains(c, apitest.DefaultToken) {
+		t.Errorf("token not stored in the keyring:\n%s", c)
+	}
+	if token, err := keyring.Get("oh", "default"); err != nil || token != apitest.DefaultToken {
+		t.Errorf("keyring holds %q, %v", token, err)
+	}
+
+	// without a keyring it is encrypted, if there is a passphrase
+	keyring.MockInitWithError(errors.New("no keyring"))
+	env.writeConfig("")
+	r := env.run("token", apitest.DefaultToken)
+	if r.code != 1 || !strings.Contains(r.stderr, "--store plain") {
+		t.Errorf("no keyring and no passphrase: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if c := env.readConfig(); c != "" {
+		t.Errorf("token stored without a keyring or passphrase:\n%s", c)
+	}
+
+	t.Setenv("OH_PASSPHRASE", "secret")
+	env.mustRun("token", apitest.DefaultToken)
+	if c := env.readConfig(); !strings.Contains(c, "token_ref: file:") || strings.Contains(c, apitest.DefaultToken) {
+		t.Errorf("token not stored in the encrypted file:\n%s", c)
+	}
+
+	// plaintext only when asked for
+	env.mustRun("token", apitest.DefaultToken, "--store", "plain")
+	if c := env.readConfig(); !strings.Contains(c, "token: "+apitest.DefaultToken) || strings.Contains(c, "token_ref") {
+		t.Errorf("token not stored in plaintext:\n%s", c)
+	}
+}
+
+func TestTokenVerify(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.run("token", "wrong", "--store", "plain")
+	if r.code != 1 || !strings.Contains(r.stderr, "the token was rejected by the API, it was not stored") {
+		t.Errorf("rejected token: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if c := env.readConfig(); c != "" {
+		t.Errorf("rejected token stored:\n%s", c)
+	}
+
+	r = env.mustRun("token", apitest.DefaultToken, "--store", "plain")
+	if !strings.Contains(r.stderr, "Logged in as Example GmbH (4711)") {
+		t.Errorf("verified token: stderr %q", r.stderr)
+	}
+
+	// --no-verify does not ask the API at all
+	before := len(env.requests())
+	r = env.mustRun("token", "unchecked", "--store", "plain", "--no-verify")
+	if r.stderr != "🔐 Token stored!\n" {
+		t.Errorf("unverified token: stderr %q", r.stderr)
+	}
+	if got := env.requests()[before:]; len(got) != 0 {
+		t.Errorf("--no-verify sent %v", got)
+	}
+	if c := env.readConfig(); !strings.Contains(c, "token: unchecked") {
+		t.Errorf("unverified token not stored:\n%s", c)
+	}
+}
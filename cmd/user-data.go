@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/cloudinit"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var userDataFiles []string
+
+// errCloudInitDisabled is returned when user-data is given while the API is not known to accept it
+var errCloudInitDisabled = fmt.Errorf("user-data is not supported by the API yet; set cloud_init.enabled: true in the config once it is")
+
+// loadUserData assembles the --user-data files and checks them locally, or returns ""
+// when none were given
+func loadUserData() (string, error) {
+	if len(userDataFiles) == 0 {
+		return "", nil
+	}
+	if !viper.GetBool("cloud_init.enabled") {
+		return "", errCloudInitDisabled
+	}
+
+	userData, err := cloudinit.Load(userDataFiles...)
+	if err != nil {
+		return "", err
+	}
+	if err := cloudinit.Validate(userData); err != nil {
+		return "", fmt.Errorf("invalid user-data: %w", err)
+	}
+	return userData, nil
+}
+
+// applyUserData sets the --user-data on every order. An order template may carry
+// userData itself, which is subject to the same config switch.
+func applyUserData(orders []api.CloudServerOrder) error {
+	userData, err := loadUserData()
+	if err != nil {
+		return err
+	}
+	for i := range orders {
+		if userData != "" {
+			orders[i].UserData = userData
+		}
+		if orders[i].UserData != "" && !viper.GetBool("cloud_init.enabled") {
+			return errCloudInitDisabled
+		}
+	}
+	return nil
+}
+
+// addUserDataFlag adds --user-data to a command that can bootstrap a server with cloud-init
+func addUserDataFlag(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&userDataFiles, "user-data", nil,
+		"cloud-init user-data file; repeat it or use an #include file to send several parts")
+}
@@ -1,11 +1,12 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/edvin/oh/api"
 	"github.com/edvin/oh/ui"
 	"github.com/spf13/cobra"
-	"strconv"
 	"strings"
 )
 
@@ -13,6 +14,9 @@ var (
 	resetImageId  int
 	resetName     string
 	resetPassword string
+
+	executeAll     bool
+	executeFilters []string
 )
 
 var validVpsActions = []string{
@@ -31,46 +35,168 @@ var validVpsActionSet = func() map[string]struct{} {
 }()
 
 var vpsActionCmd = &cobra.Command{
-	Use:               "execute <vps-id> <action>",
-	Short:             "Execute an action on a VPS (soft-reboot, hard-reboot, power-off, power-on, reset)",
-	Long:              `Run one of the VirtualServerAction (soft-reboot, hard-reboot, power-off, power-on, reset) against a given VPS ID.`,
+	Use:   "execute <vps>... <action>",
+	Short: "Execute an action on one or more VPS (soft-reboot, hard-reboot, power-off, power-on, reset)",
+	Long: `Run one of the VirtualServerAction (soft-reboot, hard-reboot, power-off, power-on, reset) against the given VPS.
+
+The action is always the last argument. When several servers are given, with a glob or filter
+matching several servers, or with --all, the action runs on up to --parallel servers at a time and
+a result is shown per server. The command fails if the action failed on any server.
+
+--filter narrows the servers down further, and completion only suggests those it matches.
+
+` + filterHelp,
+	Example: `  # reboot a single server
+  oh vps execute 42 soft-reboot
+
+  # reboot every web server, four at a time, even if some fail
+  oh vps execute 'web-*' soft-reboot --parallel 4 --continue-on-error
+
+  # power on every stopped server and wait until they are up
+  oh vps execute status=stopped power-on --wait
+
+  # reboot the servers in a private network
+  oh vps execute --all --filter 'ipv4 in 10.0.0.0/8' soft-reboot`,
 	SilenceUsage:      true,
 	Args:              validateVpsExecuteArgs,
 	ValidArgsFunction: completeVpsExecuteArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		vpsId, err := strconv.Atoi(args[0])
-		if err != nil {
-			return fmt.Errorf("invalid VPS Id %q: %w", args[0], err)
+		refs, action := args[:len(args)-1], api.VirtualServerAction(args[len(args)-1])
+		if len(userDataFiles) > 0 && action != api.VirtualServerReset {
+			return fmt.Errorf("--user-data only applies to reset")
 		}
-		action := api.VirtualServerAction(args[1])
 
-		var request any
+		if executeAll || len(refs) > 1 || isServerPattern(refs[0]) || len(executeFilters) > 0 {
+			return executeBulk(cmd, refs, action)
+		}
 
+		// all arguments are checked before anyone is asked to confirm
+		var request any
 		if action == api.VirtualServerReset {
-			err = validateResetCommand()
-			if err != nil {
+			if err := validateResetCommand(); err != nil {
 				return err
 			}
 
+			userData, err := loadUserData()
+			if err != nil {
+				return err
+			}
 			request = api.ResetCloudServerRequest{
 				ImageId:  resetImageId,
 				Name:     resetName,
 				Password: resetPassword,
+				UserData: userData,
 			}
 		}
 
-		resp, err := api.ExecuteVirtualServerAction(vpsId, action, request)
+		vpsId, err := resolveServerId(cmd.Context(), refs[0])
 		if err != nil {
 			return err
 		}
-		if printed, err := PrintJSON(resp, cmd); printed {
+
+		var server api.CloudServer
+		if destructiveActions[action] || waitEnabled {
+			// shown when confirming, and the status a wait has to see the server leave
+			if server, err = apiClient().GetVirtualServer(cmd.Context(), vpsId); err != nil {
+				return err
+			}
+		}
+		if destructiveActions[action] {
+			if err := confirm([]api.CloudServer{server}, string(action), action == api.VirtualServerReset); err != nil {
+				return err
+			}
+		}
+
+		resp, err := apiClient().ExecuteVirtualServerAction(cmd.Context(), vpsId, action, request)
+		if err != nil {
 			return err
 		}
 
-		return ui.RenderForm(resp, actionResponseColumns()...)
+		if waitEnabled {
+			if _, err := waitForStatus(cmd.Context(), vpsId, expectedStatus(action), server.Status, waitTimeout); err != nil {
+				return err
+			}
+		}
+		return printItem(cmd, resp, actionResponseColumns()...)
 	},
 }
 
+// executeBulk runs the action on every referenced server and reports a result per server
+func executeBulk(cmd *cobra.Command, refs []string, action api.VirtualServerAction) error {
+	if action == api.VirtualServerReset {
+		// the name and password given for a reset belong to a single server
+		return fmt.Errorf("%s can only be executed on a single VPS", action)
+	}
+	if bulkParallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1, got %d", bulkParallel)
+	}
+
+	servers, err := resolveServerList(cmd.Context(), refs, executeAll)
+	if err != nil {
+		return err
+	}
+	if servers, err = filterItems(servers, executeFilters); err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("no VPS matches --filter %s", strings.Join(executeFilters, " --filter "))
+	}
+	if destructiveActions[action] {
+		if err := confirm(servers, string(action), false); err != nil {
+			return err
+		}
+	}
+
+	opts := bulkOptions{
+		Parallel:        bulkParallel,
+		ContinueOnError: continueOnError,
+		Progress:        fmt.Sprintf("Executing %s on %%d servers", action),
+	}
+	results := forEachServer(cmd.Context(), servers, opts, func(ctx context.Context, server api.CloudServer) (string, error) {
+		if waitEnabled {
+			// the listed status may be cached, the wait has to start from the current one
+			current, err := apiClient().GetVirtualServer(ctx, server.Id)
+			if err != nil {
+				return "", err
+			}
+			server = current
+		}
+		resp, err := apiClient().ExecuteVirtualServerAction(ctx, server.Id, action, nil)
+		if errors.Is(err, api.ErrDryRun) {
+			return "dry run, not sent", nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if !waitEnabled {
+			return resp.Message, nil
+		}
+
+		status := expectedStatus(action)
+		waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+		defer cancel()
+		waitOpts := api.DefaultWaitOptions
+		waitOpts.From = server.Status
+		if _, err := apiClient().WaitForStatus(waitCtx, server.Id, status, waitOpts); err != nil {
+			return "", fmt.Errorf("%s, but waiting for %s failed: %w", resp.Message, status, err)
+		}
+		return fmt.Sprintf("%s, now %s", resp.Message, status), nil
+	})
+
+	if err := printList(cmd, results, bulkResultColumns()...); err != nil {
+		return err
+	}
+	return bulkError(results)
+}
+
+// expectedStatus is the status a server ends up in after the action
+func expectedStatus(action api.VirtualServerAction) string {
+	if action == api.VirtualServerPowerOff {
+		return "stopped"
+	}
+	return "active"
+}
+
 func validateResetCommand() error {
 	if resetImageId == 0 {
 		return fmt.Errorf("please supply the image id")
@@ -85,21 +211,23 @@ func validateResetCommand() error {
 }
 
 func validateVpsExecuteArgs(cmd *cobra.Command, args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("vps-id is required")
-	}
-
-	if len(args) < 2 {
+	switch {
+	case executeAll && len(args) > 1:
+		return fmt.Errorf("--all cannot be combined with VPS arguments")
+	case executeAll && len(args) == 0, !executeAll && len(args) == 1:
 		return fmt.Errorf(
 			"action is required; must be one of [%s]",
 			strings.Join(validVpsActions, ", "),
 		)
+	case len(args) == 0:
+		return fmt.Errorf("vps is required")
 	}
 
-	if _, ok := validVpsActionSet[args[1]]; !ok {
+	action := args[len(args)-1]
+	if _, ok := validVpsActionSet[action]; !ok {
 		return fmt.Errorf(
 			"invalid action %q; must be one of [%s]",
-			args[1],
+			action,
 			strings.Join(validVpsActions, ", "),
 		)
 	}
@@ -107,30 +235,36 @@ func validateVpsExecuteArgs(cmd *cobra.Command, args []string) error {
 }
 
 func completeVpsExecuteArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	switch len(args) {
-	// Complete VPS ids by dynamic API lookup
-	case 0:
-		return completeVpsIds(cmd, args, toComplete)
+	if len(args) > 0 {
+		if _, ok := validVpsActionSet[args[len(args)-1]]; ok {
+			// the action comes last, nothing follows it
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+	}
 
-	case 1:
-		// Validate vps actions
-		var comps []string
-		for _, a := range validVpsActions {
-			if strings.HasPrefix(a, toComplete) {
-				comps = append(comps, a)
-			}
+	var comps []string
+	for _, a := range validVpsActions {
+		if strings.HasPrefix(a, toComplete) {
+			comps = append(comps, a)
 		}
+	}
+	if executeAll {
 		return comps, cobra.ShellCompDirectiveNoFileComp
-
-	default:
-		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
+	if len(args) == 0 {
+		// Complete VPS ids by dynamic API lookup
+		return completeVpsIds(cmd, args, toComplete)
+	}
+
+	// either another VPS or the action
+	servers, directive := completeVpsIds(cmd, nil, toComplete)
+	return append(comps, servers...), directive
 }
 
 func actionResponseColumns() []ui.TableColumn[api.VirtualServerActionResponse] {
 	return []ui.TableColumn[api.VirtualServerActionResponse]{
-		ui.Column("Id", 11, func(i api.VirtualServerActionResponse) int { return i.Id }),
-		ui.Column("Message", 35, func(i api.VirtualServerActionResponse) string { return i.Message }),
+		ui.Column("Id", func(i api.VirtualServerActionResponse) int { return i.Id }),
+		ui.Column("Message", func(i api.VirtualServerActionResponse) string { return i.Message }),
 	}
 }
 
@@ -138,6 +272,11 @@ func init() {
 	vpsActionCmd.Flags().IntVarP(&resetImageId, "image-id", "i", 0, "ID of the image to reset")
 	vpsActionCmd.Flags().StringVarP(&resetName, "name", "n", "", "Name of the virtual server")
 	vpsActionCmd.Flags().StringVarP(&resetPassword, "password", "p", "", "Password of the virtual server")
+	addUserDataFlag(vpsActionCmd)
+	vpsActionCmd.Flags().BoolVar(&executeAll, "all", false, "Execute the action on every VPS")
+	vpsActionCmd.Flags().StringArrayVar(&executeFilters, "filter", nil, "Execute the action only on the servers that match, e.g. status=active (repeatable)")
+	addBulkFlags(vpsActionCmd)
+	addWaitFlags(vpsActionCmd)
 
 	vpsCmd.AddCommand(vpsActionCmd)
 }
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/ui"
+	"github.com/spf13/cobra"
+	"sync"
+)
+
+const (
+	outcomeOk      = "ok"
+	outcomeFailed  = "failed"
+	outcomeSkipped = "skipped"
+)
+
+// bulkResult is the outcome of an operation on one server of a bulk command
+type bulkResult struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Outcome string `json:"outcome"`
+	Message string `json:"message"`
+}
+
+var (
+	bulkParallel    int
+	continueOnError bool
+)
+
+// bulkOptions controls how fanOut runs
+type bulkOptions struct {
+	Parallel        int
+	ContinueOnError bool
+	// Progress is shown in a spinner, formatted with the number of items,
+	// e.g. "Executing soft-reboot on %d servers"
+	Progress string
+}
+
+// errNotStarted marks the items fanOut did not start
+var errNotStarted = errors.New("not started")
+
+// fanOut runs fn for every item, at most opts.Parallel at a time, and returns the error
+// of each item in the order given. Unless opts.ContinueOnError is set, no new items are
+// started after the first failure and errNotStarted is returned for them; those already
+// running are let finish since their request may have been sent.
+func fanOut[T any](
+	ctx context.Context,
+	items []T,
+	opts bulkOptions,
+	fn func(ctx context.Context, i int, item T) error,
+) []error {
+	parallel := max(opts.Parallel, 1)
+	errs := make([]error, len(items))
+
+	progress := fmt.Sprintf(opts.Progress, len(items))
+	spinner := ui.StartSpinner(progress)
+	defer spinner.Stop()
+
+	var (
+		mu     sync.Mutex
+		done   int
+		failed bool
+		wg     sync.WaitGroup
+		sem    = make(chan struct{}, parallel)
+	)
+
+	for i, item := range items {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed && !opts.ContinueOnError
+		mu.Unlock()
+		if ctx.Err() != nil {
+			<-sem
+			errs[i] = fmt.Errorf("%w: %w", errNotStarted, ctx.Err())
+			continue
+		}
+		if stop {
+			<-sem
+			errs[i] = fmt.Errorf("%w after an earlier failure", errNotStarted)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, item T) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := fn(ctx, i, item)
+
+			mu.Lock()
+			defer mu.Unlock()
+			errs[i] = err
+			failed = failed || err != nil
+			done++
+			spinner.Update(fmt.Sprintf("%s (%d done)", progress, done))
+		}(i, item)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// forEachServer runs fn for every server with fanOut, and returns one result per server
+func forEachServer(
+	ctx context.Context,
+	servers []api.CloudServer,
+	opts bulkOptions,
+	fn func(ctx context.Context, server api.CloudServer) (string, error),
+) []bulkResult {
+	results := make([]bulkResult, len(servers))
+	errs := fanOut(ctx, servers, opts, func(ctx context.Context, i int, server api.CloudServer) error {
+		msg, err := fn(ctx, server)
+		results[i].Message = msg
+		return err
+	})
+
+	for i, server := range servers {
+		results[i].Id, results[i].Name = server.Id, server.Name
+		results[i].Outcome, results[i].Message = outcome(errs[i], results[i].Message)
+	}
+	return results
+}
+
+// outcome classifies the error of a fanOut item and returns the message to show for it
+func outcome(err error, msg string) (string, string) {
+	switch {
+	case err == nil:
+		return outcomeOk, msg
+	case errors.Is(err, errNotStarted):
+		return outcomeSkipped, err.Error()
+	default:
+		return outcomeFailed, err.Error()
+	}
+}
+
+// bulkError summarizes the failures in results, or returns nil if all succeeded
+func bulkError(results []bulkResult) error {
+	var failed, skipped int
+	for _, r := range results {
+		switch r.Outcome {
+		case outcomeFailed:
+			failed++
+		case outcomeSkipped:
+			skipped++
+		}
+	}
+	switch {
+	case failed == 0 && skipped == 0:
+		return nil
+	case skipped == 0:
+		return fmt.Errorf("%d of %d servers failed", failed, len(results))
+	default:
+		return fmt.Errorf("%d of %d servers failed, %d skipped", failed, len(results), skipped)
+	}
+}
+
+func bulkResultColumns() []ui.TableColumn[bulkResult] {
+	return []ui.TableColumn[bulkResult]{
+		ui.Column("Id", func(r bulkResult) int { return r.Id }),
+		ui.Column("Name", func(r bulkResult) string { return r.Name }),
+		ui.Column("Outcome", func(r bulkResult) ui.Status { return ui.Status(r.Outcome) }),
+		ui.Column("Message", func(r bulkResult) string { return r.Message }),
+	}
+}
+
+// addBulkFlags adds --parallel and --continue-on-error to a command acting on many items
+func addBulkFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&bulkParallel, "parallel", 4, "Number of requests to run at the same time")
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "Keep starting requests after one has failed")
+}
@@ -19,7 +19,7 @@ var vpsFlavourCmd = &cobra.Command{
 }
 
 var listFlavoursCmd = &cobra.Command{
-	Use:   "list [server-id]",
+	Use:   "list <vps>",
 	Short: "List Possible Flavours for VPS",
 	Long: `This endpoint can be used only if it is included in your subscription (your support representative can provide more information regarding how to include it with your subscription).
 
@@ -28,28 +28,24 @@ Returns a list of compatible flavours for a specific VPS. These can be used to c
 	ValidArgsFunction: completeVpsIds,
 	SilenceUsage:      true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		serverId, err := strconv.Atoi(args[0])
+		serverId, err := resolveServerId(cmd.Context(), args[0])
 		if err != nil {
-			return fmt.Errorf("invalid server Id %q: %w", args[0], err)
+			return err
 		}
 
 		flavours, err := cache.Call(cache.KeyFlavours.WithArg(serverId), cache.DefaultTTL, func() ([]api.CloudServerFlavour, error) {
-			return api.ListVpsFlavours(serverId)
+			return apiClient().ListVpsFlavours(cmd.Context(), serverId)
 		})
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(flavours, cmd); printed {
-			return err
-		}
-
-		return ui.RenderTable(flavours, flavourColumns()...)
+		return printList(cmd, flavours, flavourColumns()...)
 	},
 }
 
 var changeFlavourCmd = &cobra.Command{
-	Use:   "set [server-id]",
+	Use:   "set <vps>",
 	Short: "Change Flavour of a VPS",
 	Long: `This endpoint can be used only if it is included in your subscription (your support representative can provide more information regarding how to include it with your subscription).
 
@@ -58,47 +54,59 @@ Changes the current flavour (configuration) of a specified VPS instance.`,
 	ValidArgsFunction: completeVpsIds,
 	SilenceUsage:      true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		serverId, err := strconv.Atoi(args[0])
+		serverId, err := resolveServerId(cmd.Context(), args[0])
 		if err != nil {
-			return fmt.Errorf("invalid server Id %q: %w", args[0], err)
+			return err
+		}
+
+		var before api.CloudServer
+		if waitEnabled {
+			// the server is active before and after the change, so the wait has to see it go
+			if before, err = apiClient().GetVirtualServer(cmd.Context(), serverId); err != nil {
+				return err
+			}
 		}
 
-		response, err := api.ChangeVpsFlavour(serverId, flavourId)
+		response, err := apiClient().ChangeVpsFlavour(cmd.Context(), serverId, flavourId)
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(response, cmd); printed {
-			return err
+		if waitEnabled {
+			if _, err := waitForStatus(cmd.Context(), serverId, "active", before.Status, waitTimeout); err != nil {
+				return err
+			}
 		}
 
-		return ui.RenderForm(response, changeFlavourColumns()...)
+		return printItem(cmd, response, changeFlavourColumns()...)
 	},
 }
 
 func init() {
 	changeFlavourCmd.Flags().IntVarP(&flavourId, "flavour", "f", 0, "The new Flavour ID")
 	changeFlavourCmd.RegisterFlagCompletionFunc("flavour", completeFlavoursForServer)
+	addWaitFlags(changeFlavourCmd)
+	addListFlags(listFlavoursCmd, ui.ColumnKeys(flavourColumns()))
 	vpsFlavourCmd.AddCommand(listFlavoursCmd, changeFlavourCmd)
 	vpsCmd.AddCommand(vpsFlavourCmd)
 }
 
 func flavourColumns() []ui.TableColumn[api.CloudServerFlavour] {
 	return []ui.TableColumn[api.CloudServerFlavour]{
-		ui.Column("Id", 11, func(f api.CloudServerFlavour) int { return f.Id }),
-		ui.Column("Name", 35, func(f api.CloudServerFlavour) string { return f.Name }),
-		ui.Column("Cores", 10, func(f api.CloudServerFlavour) int { return f.Cores }),
-		ui.Column("Ram Size", 10, func(f api.CloudServerFlavour) int { return f.RamSize }),
-		ui.Column("Storage Type", 15, func(f api.CloudServerFlavour) string { return f.StorageType }),
-		ui.Column("Storage Size", 15, func(f api.CloudServerFlavour) int { return f.StorageSize }),
+		ui.Column("Id", func(f api.CloudServerFlavour) int { return f.Id }),
+		ui.Column("Name", func(f api.CloudServerFlavour) string { return f.Name }),
+		ui.Column("Cores", func(f api.CloudServerFlavour) int { return f.Cores }),
+		ui.Column("Ram Size", func(f api.CloudServerFlavour) ui.Megabytes { return ui.Megabytes(f.RamSize) }),
+		ui.Column("Storage Type", func(f api.CloudServerFlavour) string { return f.StorageType }),
+		ui.Column("Storage Size", func(f api.CloudServerFlavour) ui.Gigabytes { return ui.Gigabytes(f.StorageSize) }),
 	}
 }
 
 func changeFlavourColumns() []ui.TableColumn[api.ChangeFlavourResponse] {
 	return []ui.TableColumn[api.ChangeFlavourResponse]{
-		ui.Column("ServerId", 11, func(f api.ChangeFlavourResponse) int { return f.ServerId }),
-		ui.Column("FlavourId", 11, func(f api.ChangeFlavourResponse) int { return f.FlavourId }),
-		ui.Column("Message", 40, func(f api.ChangeFlavourResponse) string { return f.Message }),
+		ui.Column("ServerId", func(f api.ChangeFlavourResponse) int { return f.ServerId }),
+		ui.Column("FlavourId", func(f api.ChangeFlavourResponse) int { return f.FlavourId }),
+		ui.Column("Message", func(f api.ChangeFlavourResponse) string { return f.Message }),
 	}
 }
 
@@ -108,13 +116,13 @@ func completeFlavoursForServer(cmd *cobra.Command, args []string, toComplete str
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	serverId, err := strconv.Atoi(args[0])
+	serverId, err := resolveServerId(cmd.Context(), args[0])
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
 
-	flavours, err := cache.Call(cache.KeyFlavours, cache.DefaultTTL, func() ([]api.CloudServerFlavour, error) {
-		return api.ListVpsFlavours(serverId)
+	flavours, err := cache.Call(cache.KeyFlavours.WithArg(serverId), cache.DefaultTTL, func() ([]api.CloudServerFlavour, error) {
+		return apiClient().ListVpsFlavours(cmd.Context(), serverId)
 	})
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
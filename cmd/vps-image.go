@@ -24,17 +24,13 @@ var listVpsImagesCmd = &cobra.Command{
 	ValidArgsFunction: NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		images, err := cache.Call(cache.KeyVpsImages, cache.DefaultTTL, func() ([]api.CloudServerImage, error) {
-			return api.ListVpsImages()
+			return apiClient().ListVpsImages(cmd.Context())
 		})
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(images, cmd); printed {
-			return err
-		}
-
-		return ui.RenderTable(images, imageColumns()...)
+		return printList(cmd, images, imageColumns()...)
 	},
 }
 
@@ -52,36 +48,33 @@ var getVpsImageCmd = &cobra.Command{
 		}
 
 		image, err := cache.Call(cache.KeyVpsImages.WithArg(id), 24*time.Hour, func() (api.CloudServerImage, error) {
-			return api.GetVpsImage(id)
+			return apiClient().GetVpsImage(cmd.Context(), id)
 		})
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(image, cmd); printed {
-			return err
-		}
-
-		return ui.RenderForm(image, imageColumns()...)
+		return printItem(cmd, image, imageColumns()...)
 	},
 }
 
 func init() {
+	addListFlags(listVpsImagesCmd, ui.ColumnKeys(imageColumns()))
 	vpsImageCmd.AddCommand(getVpsImageCmd, listVpsImagesCmd)
 	vpsCmd.AddCommand(vpsImageCmd)
 }
 
 func imageColumns() []ui.TableColumn[api.CloudServerImage] {
 	return []ui.TableColumn[api.CloudServerImage]{
-		ui.Column("Id", 11, func(i api.CloudServerImage) int { return i.Id }),
-		ui.Column("Name", 35, func(i api.CloudServerImage) string { return i.Name }),
-		ui.Column("Distro", 10, func(i api.CloudServerImage) string { return i.OSDistro }),
-		ui.Column("Version", 10, func(i api.CloudServerImage) string { return i.OSVersion }),
-		ui.Column("Release Date", 25, func(i api.CloudServerImage) api.Date { return i.ReleaseDate }),
-		ui.Column("Size", 15, func(i api.CloudServerImage) api.Size64 { return i.Size }),
-		ui.Column("Virtual Size", 15, func(i api.CloudServerImage) api.Size64 { return i.VirtualSize }),
-		ui.Column("Min RAM", 10, func(i api.CloudServerImage) int { return i.MinRAM }),
-		ui.Column("Min Disk", 10, func(i api.CloudServerImage) int { return i.MinDisk }),
+		ui.Column("Id", func(i api.CloudServerImage) int { return i.Id }),
+		ui.Column("Name", func(i api.CloudServerImage) string { return i.Name }),
+		ui.Column("Distro", func(i api.CloudServerImage) string { return i.OSDistro }),
+		ui.Column("Version", func(i api.CloudServerImage) string { return i.OSVersion }),
+		ui.Column("Release Date", func(i api.CloudServerImage) api.Date { return i.ReleaseDate }),
+		ui.Column("Size", func(i api.CloudServerImage) api.Size64 { return i.Size }),
+		ui.WideColumn("Virtual Size", func(i api.CloudServerImage) api.Size64 { return i.VirtualSize }),
+		ui.WideColumn("Min RAM", func(i api.CloudServerImage) ui.Megabytes { return ui.Megabytes(i.MinRAM) }),
+		ui.WideColumn("Min Disk", func(i api.CloudServerImage) ui.Gigabytes { return ui.Gigabytes(i.MinDisk) }),
 	}
 }
 
@@ -91,7 +84,7 @@ func completeVpsImageIds(cmd *cobra.Command, args []string, toComplete string) (
 	}
 
 	vpsList, err := cache.Call(cache.KeyVpsImages, cache.DefaultTTL, func() ([]api.CloudServerImage, error) {
-		return api.ListVpsImages()
+		return apiClient().ListVpsImages(cmd.Context())
 	})
 
 	if err != nil {
@@ -9,7 +9,7 @@ import (
 	"github.com/edvin/oh/ui"
 	"github.com/spf13/cobra"
 	"net"
-	"strconv"
+	"slices"
 	"strings"
 	"time"
 )
@@ -36,22 +36,41 @@ var listAvailableNetworksCmd = &cobra.Command{
 Lists all available Virtual Networks as array each containing array(s) of subnet related information.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		networks, err := cache.Call(cache.KeyVirtualNetworks, cache.DefaultTTL, func() ([]api.VirtualNetwork, error) {
-			return api.ListVirtualNetworks()
+			return apiClient().ListVirtualNetworks(cmd.Context())
 		})
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(networks, cmd); printed {
+		return printList(cmd, networks, networkColumns()...)
+	},
+}
+
+var getAvailableNetworkCmd = &cobra.Command{
+	Use:               "get-available <network>",
+	Short:             "Get Available Virtual Network Details",
+	Long:              `Shows an available Virtual Network with a table of its subnets.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeAvailableNetworkIds,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		networks, err := cache.Call(cache.KeyVirtualNetworks, cache.DefaultTTL, func() ([]api.VirtualNetwork, error) {
+			return apiClient().ListVirtualNetworks(cmd.Context())
+		})
+		if err != nil {
 			return err
 		}
+		i := slices.IndexFunc(networks, func(n api.VirtualNetwork) bool { return n.Id == args[0] })
+		if i < 0 {
+			return fmt.Errorf("no available network with Id %q", args[0])
+		}
 
-		return ui.RenderTable(networks, networkColumns()...)
+		return printItem(cmd, networks[i], networkColumns()...)
 	},
 }
 
 var listAttachedNetworksCmd = &cobra.Command{
-	Use:               "list [server-id]",
+	Use:               "list <vps>",
 	Short:             "List Attached Virtual Networks on VPS",
 	SilenceUsage:      true,
 	ValidArgsFunction: completeVpsIds,
@@ -70,81 +89,90 @@ var listAttachedNetworksCmd = &cobra.Command{
 
 Return list of all attached networks on specified VPS.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		serverId, err := strconv.Atoi(args[0])
+		serverId, err := resolveServerId(cmd.Context(), args[0])
 		if err != nil {
-			return fmt.Errorf("invalid server Id %q: %w", args[0], err)
+			return err
 		}
 
-		networks, err := cache.Call(cache.KeyFlavours.WithArg(serverId), time.Minute, func() ([]api.AttachedNetwork, error) {
-			return api.ListAttachedVirtualNetworks(serverId)
+		networks, err := cache.Call(cache.KeyAttachedNetworks.WithArg(serverId), time.Minute, func() ([]api.AttachedNetwork, error) {
+			return apiClient().ListAttachedVirtualNetworks(cmd.Context(), serverId)
 		})
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(networks, cmd); printed {
-			return err
-		}
-
-		return ui.RenderTable(networks, attachedNetworkColumns()...)
+		return printList(cmd, networks, attachedNetworkColumns()...)
 	},
 }
 
 var detachNetworksCmd = &cobra.Command{
-	Use:               "detach [server-id]",
+	Use:               "detach <vps>",
 	Short:             "Detach virtual network from server instance",
 	SilenceUsage:      true,
 	Args:              validateSingleVpsIdArg,
 	ValidArgsFunction: completeVpsIds,
 	Long:              `Detach virtual network from server instance.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		serverId, err := strconv.Atoi(args[0])
+		serverId, err := resolveServerId(cmd.Context(), args[0])
 		if err != nil {
-			return fmt.Errorf("invalid server Id %q: %w", args[0], err)
+			return err
 		}
 
-		response, err := api.DetachVirtualNetwork(serverId, detachNetId)
+		server, err := apiClient().GetVirtualServer(cmd.Context(), serverId)
 		if err != nil {
 			return err
 		}
+		if err := confirm([]api.CloudServer{server}, fmt.Sprintf("detach network %s from", detachNetId), false); err != nil {
+			return err
+		}
 
-		if printed, err := PrintJSON(response, cmd); printed {
+		response, err := apiClient().DetachVirtualNetwork(cmd.Context(), serverId, detachNetId)
+		if err != nil {
 			return err
 		}
 
-		return ui.RenderForm(response, detachNetworkResponseColumns()...)
+		if waitEnabled {
+			if err := waitForNetwork(cmd.Context(), serverId, detachNetId, false, waitTimeout); err != nil {
+				return err
+			}
+		}
+
+		return printItem(cmd, response, detachNetworkResponseColumns()...)
 	},
 }
 
 var attachNetworksCmd = &cobra.Command{
-	Use:               "attach [server-id]",
+	Use:               "attach <vps>",
 	Short:             "Attach virtual network to server instance",
 	SilenceUsage:      true,
 	Args:              validateSingleVpsIdArg,
 	ValidArgsFunction: completeVpsIds,
 	Long:              `Attach virtual network to server instance.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		serverId, err := strconv.Atoi(args[0])
+		serverId, err := resolveServerId(cmd.Context(), args[0])
 		if err != nil {
-			return fmt.Errorf("invalid server Id %q: %w", args[0], err)
+			return err
 		}
 
-		response, err := api.AttachVirtualNetwork(serverId, attachNetId, attachIPv4, attachIPv6)
+		response, err := apiClient().AttachVirtualNetwork(cmd.Context(), serverId, attachNetId, attachIPv4, attachIPv6)
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(response, cmd); printed {
-			return err
+		if waitEnabled {
+			if err := waitForNetwork(cmd.Context(), serverId, attachNetId, true, waitTimeout); err != nil {
+				return err
+			}
 		}
 
-		return ui.RenderForm(response, attachNetworkResponseColumns()...)
+		return printItem(cmd, response, attachNetworkResponseColumns()...)
 	},
 }
 
 func init() {
 	detachNetworksCmd.Flags().StringVarP(&detachNetId, "network-id", "n", "", "Network Id to detach")
 	detachNetworksCmd.RegisterFlagCompletionFunc("network-id", completeAttachedNetworkIdsForServer)
+	addWaitFlags(detachNetworksCmd)
 
 	attachNetworksCmd.Flags().StringVarP(&attachNetId, "network-id", "n", "", "Network Id to attach")
 	attachNetworksCmd.RegisterFlagCompletionFunc("network-id", completeAvailableNetworkIds)
@@ -153,40 +181,69 @@ func init() {
 	attachNetworksCmd.RegisterFlagCompletionFunc("ipv4", completeAvailableIpv4Addresses)
 
 	attachNetworksCmd.Flags().StringVarP(&attachIPv6, "ipv6", "6", "", "IPv6 address")
+	addWaitFlags(attachNetworksCmd)
+
+	addListFlags(listAvailableNetworksCmd, ui.ColumnKeys(networkColumns()))
+	addListFlags(listAttachedNetworksCmd, ui.ColumnKeys(attachedNetworkColumns()))
 
-	vpsNetworkCommand.AddCommand(listAttachedNetworksCmd, detachNetworksCmd, attachNetworksCmd, listAvailableNetworksCmd)
+	vpsNetworkCommand.AddCommand(listAttachedNetworksCmd, detachNetworksCmd, attachNetworksCmd, listAvailableNetworksCmd, getAvailableNetworkCmd)
 	vpsCmd.AddCommand(vpsNetworkCommand)
 }
 
 func networkColumns() []ui.TableColumn[api.VirtualNetwork] {
 	return []ui.TableColumn[api.VirtualNetwork]{
-		ui.Column("Id", 40, func(i api.VirtualNetwork) string { return i.Id }),
-		ui.Column("Name", 30, func(i api.VirtualNetwork) string { return i.Name }),
-		ui.Column("Subnets", 50, func(i api.VirtualNetwork) string { return "[see json output]" }),
+		ui.Column("Id", func(i api.VirtualNetwork) string { return i.Id }),
+		ui.Column("Name", func(i api.VirtualNetwork) string { return i.Name }),
+		ui.NestedColumn("Subnets", func(i api.VirtualNetwork) []api.Subnet { return i.Subnets }, subnetsSummary, subnetColumns()...),
+	}
+}
+
+func subnetColumns() []ui.TableColumn[api.Subnet] {
+	return []ui.TableColumn[api.Subnet]{
+		ui.Column("Id", func(s api.Subnet) string { return s.Id }),
+		ui.Column("Name", func(s api.Subnet) string { return s.Name }),
+		ui.Column("IP Version", func(s api.Subnet) int { return s.IpVersion }),
+		ui.Column("CIDR", func(s api.Subnet) string { return s.Cidr }),
+		ui.Column("Allocation Pools", func(s api.Subnet) string {
+			pools := make([]string, len(s.AllocationPools))
+			for i, p := range s.AllocationPools {
+				pools[i] = p.Start + "-" + p.End
+			}
+			return strings.Join(pools, ", ")
+		}),
+	}
+}
+
+// subnetsSummary lists the CIDRs of the subnets, e.g. 1 subnet: 10.0.0.0/24
+func subnetsSummary(subnets []api.Subnet) string {
+	cidrs := make([]string, len(subnets))
+	for i, s := range subnets {
+		cidrs[i] = s.Cidr
 	}
+	return countOf(len(subnets), "subnet") + strings.Join(cidrs, ", ")
 }
 
 func attachedNetworkColumns() []ui.TableColumn[api.AttachedNetwork] {
 	return []ui.TableColumn[api.AttachedNetwork]{
-		ui.Column("Id", 40, func(i api.AttachedNetwork) string { return i.Id }),
-		ui.Column("Name", 30, func(i api.AttachedNetwork) string { return i.Name }),
-		ui.Column("IPv4", 20, func(i api.AttachedNetwork) string { return i.IPv4 }),
-		ui.Column("IPv6", 26, func(i api.AttachedNetwork) string { return i.IPv6 }),
+		ui.Column("Id", func(i api.AttachedNetwork) string { return i.Id }),
+		ui.Column("Name", func(i api.AttachedNetwork) string { return i.Name }),
+		ui.Column("IPv4", func(i api.AttachedNetwork) string { return i.IPv4 }),
+		ui.Column("IPv6", func(i api.AttachedNetwork) string { return i.IPv6 }),
 	}
 }
 
 func detachNetworkResponseColumns() []ui.TableColumn[api.DetachVirtualNetworkResponse] {
 	return []ui.TableColumn[api.DetachVirtualNetworkResponse]{
-		ui.Column("ServerId", 40, func(i api.DetachVirtualNetworkResponse) int { return i.ServerId }),
-		ui.Column("Message", 50, func(i api.DetachVirtualNetworkResponse) string { return i.Message }),
+		ui.Column("ServerId", func(i api.DetachVirtualNetworkResponse) int { return i.ServerId }),
+		ui.Column("Message", func(i api.DetachVirtualNetworkResponse) string { return i.Message }),
 	}
 }
 
 func attachNetworkResponseColumns() []ui.TableColumn[api.AttachVirtualNetworkResponse] {
 	return []ui.TableColumn[api.AttachVirtualNetworkResponse]{
-		ui.Column("ServerId", 40, func(i api.AttachVirtualNetworkResponse) int { return i.ServerId }),
-		ui.Column("NetworkId", 40, func(i api.AttachVirtualNetworkResponse) string { return i.NetworkId }),
-		ui.Column("Message", 50, func(i api.AttachVirtualNetworkResponse) string { return i.Message }),
+		ui.Column("ServerId", func(i api.AttachVirtualNetworkResponse) int { return i.ServerId }),
+		ui.Column("NetworkId", func(i api.AttachVirtualNetworkResponse) string { return i.NetworkId }),
+		ui.Column("Message", func(i api.AttachVirtualNetworkResponse) string { return i.Message }),
 	}
 }
 
@@ -195,14 +252,14 @@ func completeAttachedNetworkIdsForServer(cmd *cobra.Command, args []string, toCo
 		// no server ID yet, bail out
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
-	serverId, err := strconv.Atoi(args[0])
+	serverId, err := resolveServerId(cmd.Context(), args[0])
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
 
 	key := cache.KeyAttachedNetworks.WithArg(serverId)
 	networks, err := cache.Call(key, time.Minute, func() ([]api.AttachedNetwork, error) {
-		return api.ListAttachedVirtualNetworks(serverId)
+		return apiClient().ListAttachedVirtualNetworks(cmd.Context(), serverId)
 	})
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
@@ -219,7 +276,7 @@ func completeAttachedNetworkIdsForServer(cmd *cobra.Command, args []string, toCo
 
 func completeAvailableNetworkIds(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	networks, err := cache.Call(cache.KeyVirtualNetworks, time.Minute, func() ([]api.VirtualNetwork, error) {
-		return api.ListVirtualNetworks()
+		return apiClient().ListVirtualNetworks(cmd.Context())
 	})
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
@@ -244,7 +301,7 @@ func completeAvailableIpv4Addresses(cmd *cobra.Command, args []string, toComplet
 	}
 
 	networks, err := cache.Call(cache.KeyVirtualNetworks, time.Minute, func() ([]api.VirtualNetwork, error) {
-		return api.ListVirtualNetworks()
+		return apiClient().ListVirtualNetworks(cmd.Context())
 	})
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
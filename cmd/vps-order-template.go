@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"gopkg.in/yaml.v3"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var (
+	orderValuesFile string
+	orderSet        []string
+)
+
+// envReference matches ${NAME}; a bare $NAME is left alone so passwords may contain $
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// orderTemplate is an order file: a CloudServerOrder plus the number of servers to order
+type orderTemplate struct {
+	api.CloudServerOrder
+	Count int `json:"count"`
+}
+
+// renderOrders turns an order template into the orders it describes. The template is YAML
+// or JSON; ${NAME} is replaced with the environment variable and {{ .key }} with a value
+// from --values or --set. A count expands into that many orders with indexed names and
+// sequential fixed IPs.
+func renderOrders(src []byte, values map[string]any) ([]api.CloudServerOrder, error) {
+	src, err := expandEnv(src)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New("order").Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("invalid order template: %w", err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, values); err != nil {
+		return nil, fmt.Errorf("cannot render order template: %w", err)
+	}
+
+	// YAML is a superset of JSON, and going through JSON keeps the strict field checks
+	var doc map[string]any
+	if err := yaml.Unmarshal(rendered.Bytes(), &doc); err != nil {
+		return nil, fmt.Errorf("invalid order payload: %w", err)
+	}
+	if size, ok := doc["storageSize"].(int); ok {
+		// storageSize: 20 is the natural way to write it in YAML, the API wants a string
+		doc["storageSize"] = fmt.Sprint(size)
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order payload: %w", err)
+	}
+
+	var t orderTemplate
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return nil, fmt.Errorf("invalid order payload: %w", err)
+	}
+	return expandCount(t)
+}
+
+// expandCount returns count copies of the order. The name gets the 1-based index, either
+// where it has a verb such as web-%02d, or appended as web-1, and fixed IPs are incremented.
+func expandCount(t orderTemplate) ([]api.CloudServerOrder, error) {
+	switch {
+	case t.Count < 0:
+		return nil, fmt.Errorf("invalid order payload: count must not be negative, got %d", t.Count)
+	case t.Count <= 1 && !strings.Contains(t.Name, "%"):
+		return []api.CloudServerOrder{t.CloudServerOrder}, nil
+	}
+
+	orders := make([]api.CloudServerOrder, max(t.Count, 1))
+	for i := range orders {
+		order := t.CloudServerOrder
+		order.Name = indexedName(t.Name, i+1)
+		if strings.Contains(order.Name, "%!") {
+			return nil, fmt.Errorf("invalid order payload: name %q must contain at most one integer verb such as %%02d", t.Name)
+		}
+
+		order.Networks = make([]api.VMNetwork, len(t.Networks))
+		for j, n := range t.Networks {
+			var err error
+			if n.FixedIPv4, err = offsetIP(n.FixedIPv4, i); err != nil {
+				return nil, fmt.Errorf("invalid order payload: networks[%d].fixed_ipv4: %w", j, err)
+			}
+			if n.FixedIPv6, err = offsetIP(n.FixedIPv6, i); err != nil {
+				return nil, fmt.Errorf("invalid order payload: networks[%d].fixed_ipv6: %w", j, err)
+			}
+			order.Networks[j] = n
+		}
+		orders[i] = order
+	}
+	return orders, nil
+}
+
+func indexedName(name string, index int) string {
+	if strings.Contains(name, "%") {
+		return fmt.Sprintf(name, index)
+	}
+	return fmt.Sprintf("%s-%d", name, index)
+}
+
+// offsetIP returns the address n addresses after ip, or "" for an empty ip
+func offsetIP(ip string, n int) (string, error) {
+	if ip == "" || n == 0 {
+		return ip, nil
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", fmt.Errorf("%q is not an IP address", ip)
+	}
+	for range n {
+		if addr = addr.Next(); !addr.IsValid() {
+			return "", fmt.Errorf("no address left after %s", ip)
+		}
+	}
+	return addr.String(), nil
+}
+
+// expandEnv replaces ${NAME} with the value of the environment variable
+func expandEnv(src []byte) ([]byte, error) {
+	var missing []string
+	out := envReference.ReplaceAllFunc(src, func(ref []byte) []byte {
+		name := string(envReference.FindSubmatch(ref)[1])
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return []byte(value)
+	})
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("the order template uses unset environment variables: %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// orderValues loads the --values file and applies --set on top. Keys of --set may be
+// dotted to reach into nested values.
+func orderValues() (map[string]any, error) {
+	values := map[string]any{}
+	if orderValuesFile != "" {
+		b, err := os.ReadFile(orderValuesFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read values: %w", err)
+		}
+		if err := yaml.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("invalid values in %s: %w", orderValuesFile, err)
+		}
+		if values == nil {
+			values = map[string]any{}
+		}
+	}
+
+	for _, kv := range orderSet {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --set %q; expected key=value", kv)
+		}
+		m := values
+		path := strings.Split(key, ".")
+		for _, p := range path[:len(path)-1] {
+			next, ok := m[p].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				m[p] = next
+			}
+			m = next
+		}
+		m[path[len(path)-1]] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setOrderValues sets --values and --set for the duration of the test
+func setOrderValues(t *testing.T, values string, set ...string) {
+	t.Helper()
+	orderValuesFile, orderSet = "", set
+	if values != "" {
+		orderValuesFile = filepath.Join(t.TempDir(), "values.yaml")
+		if err := os.WriteFile(orderValuesFile, []byte(values), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { orderValuesFile, orderSet = "", nil })
+}
+
+func TestRenderOrders(t *testing.T) {
+	t.Setenv("OH_TEST_PASSWORD", "from-env")
+	setOrderValues(t, "plan: 101\nzone: nbg1\nweb:\n  image: 1\n", "zone=fra1", "web.size=30")
+
+	src := `{"productId": 10, "productPlanId": {{ .plan }}, "imageId": {{ .web.image }},
+  "password": "${OH_TEST_PASSWORD}$x", "availabilityZone": "{{ .zone }}", "name": "web-01",
+  "storageSize": "{{ .web.size }}", "networks": []}`
+	values, err := orderValues()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, err := renderOrders([]byte(src), values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 {
+		t.Fatalf("got %d orders, want 1", len(orders))
+	}
+	o := orders[0]
+	// --set wins over --values, a bare $ is left alone
+	if o.ProductPlanId != 101 || o.ImageId != 1 || o.AvailabilityZone != "fra1" || o.StorageSize != "30" || o.Password != "from-env$x" {
+		t.Errorf("unexpected order %+v", o)
+	}
+}
+
+func TestRenderOrdersYAML(t *testing.T) {
+	setOrderValues(t, "")
+	src := `
+productId: 10
+productPlanId: 100
+imageId: 1
+password: s3cret
+availabilityZone: nbg1
+name: web
+storageSize: 20
+count: 2
+networks:
+  - network: net-a
+    fixed_ipv4: 10.0.0.255
+    fixed_ipv6: fd00::ffff
+`
+	orders, err := renderOrders([]byte(src), map[string]any{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 2 {
+		t.Fatalf("got %d orders, want 2", len(orders))
+	}
+	want := []struct{ name, ipv4, ipv6 string }{
+		{"web-1", "10.0.0.255", "fd00::ffff"},
+		{"web-2", "10.0.1.0", "fd00::1:0"},
+	}
+	for i, w := range want {
+		o := orders[i]
+		if o.Name != w.name || o.StorageSize != "20" || o.Networks[0].FixedIPv4 != w.ipv4 || o.Networks[0].FixedIPv6 != w.ipv6 {
+			t.Errorf("order %d = %s %s %s %s, want %s 20 %s %s", i, o.Name, o.StorageSize, o.Networks[0].FixedIPv4, o.Networks[0].FixedIPv6, w.name, w.ipv4, w.ipv6)
+		}
+	}
+}
+
+func TestExpandCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		count int
+		want  []string
+	}{
+		{"web", 0, []string{"web"}},
+		{"web", 1, []string{"web"}},
+		{"web", 3, []string{"web-1", "web-2", "web-3"}},
+		{"web-%02d", 2, []string{"web-01", "web-02"}},
+		{"web-%02d", 0, []string{"web-01"}},
+		{"web-%d.example.com", 2, []string{"web-1.example.com", "web-2.example.com"}},
+	}
+	for _, tt := range tests {
+		var tmpl orderTemplate
+		tmpl.Name, tmpl.Count = tt.name, tt.count
+		orders, err := expandCount(tmpl)
+		if err != nil {
+			t.Errorf("%s x %d: %v", tt.name, tt.count, err)
+			continue
+		}
+		var names []string
+		for _, o := range orders {
+			names = append(names, o.Name)
+		}
+		if strings.Join(names, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s x %d = %v, want %v", tt.name, tt.count, names, tt.want)
+		}
+	}
+}
+
+func TestOffsetIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		n    int
+		want string
+	}{
+		{"", 3, ""},
+		{"10.0.0.1", 0, "10.0.0.1"},
+		{"10.0.0.254", 1, "10.0.0.255"},
+		{"10.0.0.254", 2, "10.0.1.0"},
+		{"10.0.255.255", 1, "10.1.0.0"},
+		{"fd00::ffff", 1, "fd00::1:0"},
+	}
+	for _, tt := range tests {
+		got, err := offsetIP(tt.ip, tt.n)
+		if err != nil || got != tt.want {
+			t.Errorf("offsetIP(%s, %d) = %s, %v, want %s", tt.ip, tt.n, got, err, tt.want)
+		}
+	}
+
+	if _, err := offsetIP("255.255.255.255", 1); err == nil || !strings.Contains(err.Error(), "no address left") {
+		t.Errorf("offsetIP past the last address: %v", err)
+	}
+	if _, err := offsetIP("10.0.0", 1); err == nil || !strings.Contains(err.Error(), "not an IP address") {
+		t.Errorf("offsetIP of an invalid address: %v", err)
+	}
+}
+
+func TestRenderOrdersErrors(t *testing.T) {
+	setOrderValues(t, "")
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{`{"name": "{{ .missing }}"}`, `map has no entry for key "missing"`},
+		{`{"name": "${OH_TEST_UNSET}"}`, "unset environment variables: OH_TEST_UNSET"},
+		{`{"name": "{{ .x "}`, "invalid order template"},
+		{`{"name": "web", "colour": "red"}`, `unknown field "colour"`},
+		{`{"name": "web", "count": -1}`, "count must not be negative"},
+		{`{"name": "web-%d-%d", "count": 2}`, "at most one integer verb"},
+		{`{"name": "web", "count": 2, "networks": [{"network": "n", "fixed_ipv4": "nope"}]}`, `networks[0].fixed_ipv4: "nope" is not an IP address`},
+		{`name: [`, "invalid order payload"},
+	}
+	for _, tt := range tests {
+		_, err := renderOrders([]byte(tt.src), map[string]any{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("renderOrders(%s): error %v, want %q", tt.src, err, tt.want)
+		}
+	}
+}
+
+func TestOrderValuesErrors(t *testing.T) {
+	setOrderValues(t, "", "novalue")
+	if _, err := orderValues(); err == nil || !strings.Contains(err.Error(), "expected key=value") {
+		t.Errorf("--set without =: %v", err)
+	}
+	setOrderValues(t, "a: [")
+	if _, err := orderValues(); err == nil || !strings.Contains(err.Error(), "invalid values") {
+		t.Errorf("invalid --values: %v", err)
+	}
+}
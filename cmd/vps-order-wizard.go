@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/cache"
+	vpsui "github.com/edvin/oh/ui/vps"
+	"github.com/spf13/cobra"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxPoolScan bounds how many addresses of an allocation pool are tried for a free one
+const maxPoolScan = 1 << 16
+
+// runOrderWizard builds an order interactively, then saves and/or submits it
+func runOrderWizard(cmd *cobra.Command) error {
+	if len(orderSet) > 0 || orderValuesFile != "" {
+		return fmt.Errorf("--set and --values fill in an order template, pass one with --file or as argument")
+	}
+	// loaded first, so that a broken file does not waste the answers
+	userData, err := loadUserData()
+	if err != nil {
+		return err
+	}
+
+	choices, err := orderChoices(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	order, err := vpsui.OrderWizard(choices)
+	if err != nil {
+		return err
+	}
+	order.UserData = userData
+	summary, err := vpsui.ConfirmOrder(order, choices)
+	if err != nil {
+		return err
+	}
+
+	if summary.SavePath != "" {
+		b, err := json.MarshalIndent(order, "", "  ")
+		if err != nil {
+			return err
+		}
+		// the order holds the root password
+		if err := os.WriteFile(summary.SavePath, append(b, '\n'), 0o600); err != nil {
+			return fmt.Errorf("failed to save the order: %w", err)
+		}
+		cmd.Printf("💾 Order saved to %s\n", summary.SavePath)
+	}
+	if !summary.Submit {
+		cmd.Println("Order not submitted")
+		return nil
+	}
+	return submitOrder(cmd, order)
+}
+
+// orderChoices loads everything the wizard offers to pick from
+func orderChoices(ctx context.Context) (vpsui.OrderChoices, error) {
+	var choices vpsui.OrderChoices
+
+	products, err := cache.Call(cache.KeyVpsProducts, cache.DefaultTTL, func() ([]api.Product, error) {
+		return apiClient().ListVpsProducts(ctx)
+	})
+	if err != nil {
+		return choices, err
+	}
+	images, err := cache.Call(cache.KeyVpsImages, cache.DefaultTTL, func() ([]api.CloudServerImage, error) {
+		return apiClient().ListVpsImages(ctx)
+	})
+	if err != nil {
+		return choices, err
+	}
+	servers, err := cache.Call(cache.KeyCloudServers, cache.DefaultTTL, func() ([]api.CloudServer, error) {
+		return apiClient().ListCloudServers(ctx)
+	})
+	if err != nil {
+		return choices, err
+	}
+	networks, err := cache.Call(cache.KeyVirtualNetworks, cache.DefaultTTL, func() ([]api.VirtualNetwork, error) {
+		return apiClient().ListVirtualNetworks(ctx)
+	})
+	if err != nil {
+		// networks are optional, the subscription may not include them
+		verbosef("cannot list virtual networks: %v", err)
+	}
+
+	choices.Products = products
+	choices.Images = images
+	choices.Networks = networks
+	choices.Zones = serverZones(servers)
+	choices.SshKeys = publicKeys()
+	choices.SuggestIPv4 = freeIPv4Finder(ctx, servers, networks)
+	return choices, nil
+}
+
+// serverZones returns the availability zones the servers are in
+func serverZones(servers []api.CloudServer) []string {
+	seen := map[string]bool{}
+	var zones []string
+	for _, s := range servers {
+		if s.AvailabilityZone != "" && !seen[s.AvailabilityZone] {
+			seen[s.AvailabilityZone] = true
+			zones = append(zones, s.AvailabilityZone)
+		}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+// publicKeys reads the OpenSSH public keys in ~/.ssh
+func publicKeys() map[string]string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	paths, _ := filepath.Glob(filepath.Join(home, ".ssh", "*.pub"))
+	keys := map[string]string{}
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if key := strings.TrimSpace(string(b)); key != "" {
+			keys[p] = key
+		}
+	}
+	return keys
+}
+
+// freeIPv4Finder returns a function suggesting the first address of a network's
+// allocation pools that none of the servers uses. The addresses in use are only
+// looked up once a network has been picked, since that takes a request per server.
+func freeIPv4Finder(ctx context.Context, servers []api.CloudServer, networks []api.VirtualNetwork) func(string) string {
+	var (
+		once sync.Once
+		used map[string]map[netip.Addr]bool
+	)
+	return func(networkId string) string {
+		once.Do(func() {
+			used = usedIPv4(ctx, servers)
+		})
+		for _, n := range networks {
+			if n.Id == networkId {
+				return firstFreeIPv4(n, used[networkId])
+			}
+		}
+		return ""
+	}
+}
+
+// usedIPv4 returns the addresses the servers use in each network
+func usedIPv4(ctx context.Context, servers []api.CloudServer) map[string]map[netip.Addr]bool {
+	used := map[string]map[netip.Addr]bool{}
+	for _, s := range servers {
+		attached, err := cache.Call(cache.KeyAttachedNetworks.WithArg(s.Id), cache.DefaultTTL, func() ([]api.AttachedNetwork, error) {
+			return apiClient().ListAttachedVirtualNetworks(ctx, s.Id)
+		})
+		if err != nil {
+			verbosef("cannot list networks of server %d: %v", s.Id, err)
+			continue
+		}
+		for _, a := range attached {
+			addr, err := netip.ParseAddr(a.IPv4)
+			if err != nil {
+				continue
+			}
+			if used[a.Id] == nil {
+				used[a.Id] = map[netip.Addr]bool{}
+			}
+			used[a.Id][addr] = true
+		}
+	}
+	return used
+}
+
+func firstFreeIPv4(network api.VirtualNetwork, used map[netip.Addr]bool) string {
+	for _, subnet := range network.Subnets {
+		if subnet.IpVersion != 4 {
+			continue
+		}
+		for _, pool := range subnet.AllocationPools {
+			start, err := netip.ParseAddr(pool.Start)
+			if err != nil {
+				continue
+			}
+			end, err := netip.ParseAddr(pool.End)
+			if err != nil {
+				continue
+			}
+			addr := start
+			for i := 0; i < maxPoolScan && addr.IsValid() && addr.Compare(end) <= 0; i++ {
+				if !used[addr] {
+					return addr.String()
+				}
+				addr = addr.Next()
+			}
+		}
+	}
+	return ""
+}
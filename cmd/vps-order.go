@@ -1,18 +1,21 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/cache"
 	"github.com/edvin/oh/ui"
 	"github.com/spf13/cobra"
 	"io"
 	"os"
-	"strings"
+	"slices"
 )
 
 var (
-	orderFile string
+	orderFile      string
+	skipValidation bool
 )
 
 var orderVpsCmd = &cobra.Command{
@@ -23,15 +26,31 @@ var orderVpsCmd = &cobra.Command{
 Allows ordering of a new VPS instance by specifying required information during provisioning.
 
 Pass the JSON payload describing the new VPS either as a positional argument or via --file (use '-' for stdin).
+The payload may be YAML and is a template: ${NAME} is replaced with the environment variable,
+and {{ .key }} with values from --values and --set. With "count": N, N servers are ordered
+with indexed names (web-%02d, or web-1, web-2, ...) and sequential fixed IPs, up to --parallel
+at a time.
+
+Without a payload on a terminal, a wizard asks for the product, plan, image, availability zone, storage,
+networks, password and SSH key, and offers to save the order as JSON before submitting it.
 
 Example usage:
 
+# Build the order step by step
+oh vps order
+
 # Read order from file
 oh vps order -f my-order.json
 
 # Or pipe through stdin
 cat my-order.json | oh vps order -f -
 
+# Order three servers from a template
+oh vps order -f web.yaml --set prefix=web --set ipv4=10.0.0.13
+
+# Bootstrap the server with cloud-init (needs cloud_init.enabled in the config)
+oh vps order -f my-order.json --user-data cloud-config.yaml --user-data setup.sh
+
 Example payload:
 
 {
@@ -51,55 +70,283 @@ Example payload:
 	Args:         cobra.MaximumNArgs(1),
 	SilenceUsage: true,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var reader io.Reader
-		switch {
-		case orderFile == "-":
-			reader = os.Stdin
-		case orderFile != "":
-			f, err := os.Open(orderFile)
-			if err != nil {
-				return fmt.Errorf("cannot open %q: %w", orderFile, err)
-			}
-			defer f.Close()
-			reader = f
-		case len(args) == 1:
-			reader = strings.NewReader(args[0])
-		default:
-			return fmt.Errorf("you must supply JSON via a positional arg or --file")
+		if orderFile == "" && len(args) == 0 && ui.IsTerminal(os.Stdin) && ui.IsTerminal(os.Stdout) {
+			return runOrderWizard(cmd)
 		}
 
-		// decode with strict checking
-		var order api.CloudServerOrder
-		dec := json.NewDecoder(reader)
-		dec.DisallowUnknownFields()
-		if err := dec.Decode(&order); err != nil {
-			return fmt.Errorf("invalid order payload: %w", err)
+		orders, err := readOrders(args)
+		if err != nil {
+			return err
 		}
+		if len(orders) == 1 {
+			return submitOrder(cmd, orders[0])
+		}
+		return submitOrders(cmd, orders)
+	},
+}
 
-		response, err := api.OrderVps(order)
+var validateOrderCmd = &cobra.Command{
+	Use:   "validate [<order-json>]",
+	Short: "Check an order against the available products, images and networks",
+	Long: `Checks an order payload without submitting it. The product, plan, image, storage size, SSH key,
+user-data and networks are cross-checked against the live catalog, and every problem is reported with the
+JSON path of the offending field.
+
+The same checks run before 'oh vps order' submits an order.`,
+	Example:      `  oh vps order validate -f my-order.json`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		orders, err := readOrders(args)
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(response, cmd); printed {
+		problems, err := checkOrders(cmd.Context(), orders)
+		if err != nil {
 			return err
 		}
 
-		return ui.RenderForm(response, vpsOrderColumns()...)
+		if len(problems) == 0 && tableOutput(cmd) {
+			cmd.Println("✅ The order is valid")
+			return nil
+		}
+		if err := printList(cmd, problems, validationColumns()...); err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			return nil
+		}
+		return fmt.Errorf("the order has %d problem(s)", len(problems))
 	},
 }
 
+// readOrders reads the order template from --file, stdin or the positional argument
+// and renders it into the orders it describes
+func readOrders(args []string) ([]api.CloudServerOrder, error) {
+	var (
+		src []byte
+		err error
+	)
+	switch {
+	case orderFile == "-":
+		src, err = io.ReadAll(os.Stdin)
+	case orderFile != "":
+		src, err = os.ReadFile(orderFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot open %q: %w", orderFile, err)
+		}
+	case len(args) == 1:
+		src = []byte(args[0])
+	default:
+		return nil, fmt.Errorf("you must supply JSON via a positional arg or --file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := orderValues()
+	if err != nil {
+		return nil, err
+	}
+	orders, err := renderOrders(src, values)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyUserData(orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// checkOrders validates the orders against the live catalog. The networks are only
+// checked when they can be listed, since not every subscription includes them.
+// With several orders, the paths of the problems start with the index of the order.
+func checkOrders(ctx context.Context, orders []api.CloudServerOrder) (api.ValidationErrors, error) {
+	var catalog api.OrderCatalog
+
+	products, err := cache.Call(cache.KeyVpsProducts, cache.DefaultTTL, func() ([]api.Product, error) {
+		return apiClient().ListVpsProducts(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot validate the order: %w", err)
+	}
+	images, err := cache.Call(cache.KeyVpsImages, cache.DefaultTTL, func() ([]api.CloudServerImage, error) {
+		return apiClient().ListVpsImages(ctx)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot validate the order: %w", err)
+	}
+	catalog.Products, catalog.Images = products, images
+
+	if slices.ContainsFunc(orders, func(o api.CloudServerOrder) bool { return len(o.Networks) > 0 }) {
+		networks, err := cache.Call(cache.KeyVirtualNetworks, cache.DefaultTTL, func() ([]api.VirtualNetwork, error) {
+			return apiClient().ListVirtualNetworks(ctx)
+		})
+		switch {
+		case err != nil:
+			verbosef("cannot list virtual networks, skipping their validation: %v", err)
+		case networks == nil:
+			// nil would skip the checks, but no networks means none of the order's exist
+			catalog.Networks = []api.VirtualNetwork{}
+		default:
+			catalog.Networks = networks
+		}
+	}
+
+	problems := api.ValidationErrors{}
+	for i, order := range orders {
+		for _, p := range api.ValidateOrder(order, catalog) {
+			if len(orders) > 1 {
+				p.Path = fmt.Sprintf("[%d].%s", i, p.Path)
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems, nil
+}
+
+// validateBeforeSubmit runs checkOrders unless --skip-validation was given
+func validateBeforeSubmit(ctx context.Context, orders []api.CloudServerOrder) error {
+	if skipValidation {
+		return nil
+	}
+	problems, err := checkOrders(ctx, orders)
+	if err != nil {
+		return fmt.Errorf("%w (use --skip-validation to submit anyway)", err)
+	}
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// submitOrder validates and places the order, and prints the response
+func submitOrder(cmd *cobra.Command, order api.CloudServerOrder) error {
+	if err := validateBeforeSubmit(cmd.Context(), []api.CloudServerOrder{order}); err != nil {
+		return err
+	}
+
+	response, err := apiClient().OrderVps(cmd.Context(), order)
+	if err != nil {
+		return err
+	}
+
+	if waitEnabled {
+		if _, err := waitForStatus(cmd.Context(), response.Id, "active", "", waitTimeout); err != nil {
+			return err
+		}
+	}
+
+	return printItem(cmd, response, vpsOrderColumns()...)
+}
+
+// orderResult is the outcome of one order of a multi-server order
+type orderResult struct {
+	bulkResult
+	ContractId int    `json:"contractId"`
+	OrderId    string `json:"orderId"`
+}
+
+// submitOrders validates all orders, then places them with bounded concurrency
+// and prints a result per order
+func submitOrders(cmd *cobra.Command, orders []api.CloudServerOrder) error {
+	if err := validateBeforeSubmit(cmd.Context(), orders); err != nil {
+		return err
+	}
+	if bulkParallel < 1 {
+		return fmt.Errorf("--parallel must be at least 1, got %d", bulkParallel)
+	}
+
+	results := make([]orderResult, len(orders))
+	opts := bulkOptions{
+		Parallel:        bulkParallel,
+		ContinueOnError: continueOnError,
+		Progress:        "Ordering %d servers",
+	}
+	errs := fanOut(cmd.Context(), orders, opts, func(ctx context.Context, i int, order api.CloudServerOrder) error {
+		response, err := apiClient().OrderVps(ctx, order)
+		if errors.Is(err, api.ErrDryRun) {
+			results[i].Message = "dry run, not sent"
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		results[i].Id, results[i].ContractId, results[i].OrderId = response.Id, response.ContractId, response.OrderId
+		results[i].Message = "ordered"
+
+		if waitEnabled {
+			waitCtx, cancel := context.WithTimeout(ctx, waitTimeout)
+			defer cancel()
+			if _, err := apiClient().WaitForStatus(waitCtx, response.Id, "active", api.DefaultWaitOptions); err != nil {
+				return fmt.Errorf("ordered, but waiting for active failed: %w", err)
+			}
+			results[i].Message = "ordered, now active"
+		}
+		return nil
+	})
+
+	outcomes := make([]bulkResult, len(orders))
+	for i, order := range orders {
+		results[i].Name = order.Name
+		results[i].Outcome, results[i].Message = outcome(errs[i], results[i].Message)
+		outcomes[i] = results[i].bulkResult
+	}
+
+	if err := printList(cmd, results, orderResultColumns()...); err != nil {
+		return err
+	}
+	return bulkError(outcomes)
+}
+
 func init() {
 	orderVpsCmd.Flags().
 		StringVarP(&orderFile, "file", "f", "",
 			"JSON file to read order from (`-` for stdin); if omitted you can pass raw JSON as the sole positional argument")
+	orderVpsCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Submit the order without checking it against the catalog first")
+	addTemplateFlags(orderVpsCmd)
+	addUserDataFlag(orderVpsCmd)
+	addBulkFlags(orderVpsCmd)
+	addWaitFlags(orderVpsCmd)
+
+	validateOrderCmd.Flags().
+		StringVarP(&orderFile, "file", "f", "", "JSON file to read order from (`-` for stdin)")
+	addTemplateFlags(validateOrderCmd)
+	addUserDataFlag(validateOrderCmd)
+
+	orderVpsCmd.AddCommand(validateOrderCmd)
 	vpsCmd.AddCommand(orderVpsCmd)
 }
 
+// addTemplateFlags adds the flags filling in the variables of an order template
+func addTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&orderSet, "set", nil, "Set a template value, key=value (repeatable)")
+	cmd.Flags().StringVar(&orderValuesFile, "values", "", "YAML or JSON file with template values")
+}
+
+func orderResultColumns() []ui.TableColumn[orderResult] {
+	return []ui.TableColumn[orderResult]{
+		ui.Column("Name", func(r orderResult) string { return r.Name }),
+		ui.Column("Id", func(r orderResult) int { return r.Id }),
+		ui.Column("ContractId", func(r orderResult) int { return r.ContractId }),
+		ui.Column("OrderId", func(r orderResult) string { return r.OrderId }),
+		ui.Column("Outcome", func(r orderResult) ui.Status { return ui.Status(r.Outcome) }),
+		ui.Column("Message", func(r orderResult) string { return r.Message }),
+	}
+}
+
+func validationColumns() []ui.TableColumn[api.ValidationError] {
+	return []ui.TableColumn[api.ValidationError]{
+		ui.Column("Path", func(e api.ValidationError) string { return e.Path }),
+		ui.Column("Problem", func(e api.ValidationError) string { return e.Message }),
+	}
+}
+
 func vpsOrderColumns() []ui.TableColumn[api.CloudServerOrderResponse] {
 	return []ui.TableColumn[api.CloudServerOrderResponse]{
-		ui.Column("Id", 11, func(i api.CloudServerOrderResponse) int { return i.Id }),
-		ui.Column("ContractId", 11, func(i api.CloudServerOrderResponse) int { return i.ContractId }),
-		ui.Column("OrderId", 11, func(i api.CloudServerOrderResponse) string { return i.OrderId }),
+		ui.Column("Id", func(i api.CloudServerOrderResponse) int { return i.Id }),
+		ui.Column("ContractId", func(i api.CloudServerOrderResponse) int { return i.ContractId }),
+		ui.Column("OrderId", func(i api.CloudServerOrderResponse) string { return i.OrderId }),
 	}
 }
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/edvin/oh/api"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testOrder = `{"productId": 10, "productPlanId": 100, "imageId": 1, "password": "s3cret",
+  "availabilityZone": "nbg1", "name": "web-03", "storageSize": "20",
+  "networks": [{"network": "net-a", "fixed_ipv4": "10.0.0.20"}]}`
+
+func TestVpsOrderValidate(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.mustRun("vps", "order", "validate", testOrder)
+	if !strings.Contains(r.stderr, "The order is valid") {
+		t.Errorf("valid order: stderr %q", r.stderr)
+	}
+
+	// a single order has paths without an index
+	r = env.run("vps", "order", "validate", strings.Replace(testOrder, `"imageId": 1`, `"imageId": 7`, 1), "-o", "json")
+	var problems []api.ValidationError
+	if err := json.Unmarshal([]byte(r.stdout), &problems); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+	}
+	if r.code != 1 || len(problems) != 1 || problems[0].Path != "imageId" {
+		t.Errorf("invalid order: exit %d, problems %v", r.code, problems)
+	}
+
+	// several orders have paths prefixed by the index of the order; the second one
+	// gets 10.0.0.51, outside the pool of net-a
+	orders := strings.NewReplacer(`"name": "web-03"`, `"name": "web-%02d", "count": 2`, `"10.0.0.20"`, `"10.0.0.50"`).Replace(testOrder)
+	r = env.run("vps", "order", "validate", orders, "-o", "csv")
+	if r.code != 1 || !strings.Contains(r.stderr, "the order has 1 problem(s)") {
+		t.Errorf("invalid orders: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if !strings.Contains(r.stdout, "\n[1].networks[0].fixed_ipv4,\"10.0.0.51 is outside") || strings.Contains(r.stdout, "\n[0].") {
+		t.Errorf("unexpected problems:\n%s", r.stdout)
+	}
+
+	// the same checks run before an order is submitted
+	if r := env.run("vps", "order", orders); r.code != 1 || !strings.Contains(r.stderr, "[1].networks[0].fixed_ipv4") {
+		t.Errorf("invalid order submitted: exit %d, stderr %q", r.code, r.stderr)
+	}
+
+	for _, req := range env.requests() {
+		if req.Method != http.MethodGet {
+			t.Errorf("an invalid order sent %s %s", req.Method, req.Path)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"fmt"
 	"github.com/edvin/oh/api"
 	"github.com/edvin/oh/cache"
 	"github.com/edvin/oh/ui"
 	"github.com/spf13/cobra"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 var vpsProductCmd = &cobra.Command{
@@ -22,29 +26,93 @@ var listVpsProductsCmd = &cobra.Command{
 Provides a list of products as array each containing array(s) of product plans.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		images, err := cache.Call(cache.KeyVpsProducts, cache.DefaultTTL, func() ([]api.Product, error) {
-			return api.ListVpsProducts()
+			return apiClient().ListVpsProducts(cmd.Context())
 		})
 		if err != nil {
 			return err
 		}
 
-		if printed, err := PrintJSON(images, cmd); printed {
+		return printList(cmd, images, productColumns()...)
+	},
+}
+
+var getVpsProductCmd = &cobra.Command{
+	Use:               "get <id>",
+	Short:             "Get Product Details",
+	Long:              `Shows a product with a table of its plans.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeVpsProductIds,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid product Id %q: %w", args[0], err)
+		}
+
+		products, err := cache.Call(cache.KeyVpsProducts, cache.DefaultTTL, func() ([]api.Product, error) {
+			return apiClient().ListVpsProducts(cmd.Context())
+		})
+		if err != nil {
 			return err
 		}
+		i := slices.IndexFunc(products, func(p api.Product) bool { return p.Id == id })
+		if i < 0 {
+			return fmt.Errorf("no product with Id %d", id)
+		}
 
-		return ui.RenderTable(images, productColumns()...)
+		return printItem(cmd, products[i], productColumns()...)
 	},
 }
 
 func init() {
-	vpsProductCmd.AddCommand(listVpsProductsCmd)
+	addListFlags(listVpsProductsCmd, ui.ColumnKeys(productColumns()))
+	vpsProductCmd.AddCommand(listVpsProductsCmd, getVpsProductCmd)
 	vpsCmd.AddCommand(vpsProductCmd)
 }
 
 func productColumns() []ui.TableColumn[api.Product] {
 	return []ui.TableColumn[api.Product]{
-		ui.Column("Id", 11, func(i api.Product) int { return i.Id }),
-		ui.Column("Name", 35, func(i api.Product) string { return i.Name }),
-		ui.Column("Plans", 100, func(i api.Product) string { return i.PlansString() }),
+		ui.Column("Id", func(i api.Product) int { return i.Id }),
+		ui.Column("Name", func(i api.Product) string { return i.Name }),
+		ui.NestedColumn("Plans", func(i api.Product) []api.ProductPlan { return i.Plans }, plansSummary, planColumns()...),
+	}
+}
+
+func planColumns() []ui.TableColumn[api.ProductPlan] {
+	return []ui.TableColumn[api.ProductPlan]{
+		ui.Column("Id", func(p api.ProductPlan) int { return p.Id }),
+		ui.Column("Name", func(p api.ProductPlan) string { return p.Name }),
+		ui.Column("Price", func(p api.ProductPlan) string { return strconv.FormatFloat(p.Price, 'f', 2, 64) }),
+	}
+}
+
+// plansSummary lists the plan names, e.g. 2 plans: small, large
+func plansSummary(plans []api.ProductPlan) string {
+	names := make([]string, len(plans))
+	for i, p := range plans {
+		names[i] = p.Name
+	}
+	return countOf(len(plans), "plan") + strings.Join(names, ", ")
+}
+
+func completeVpsProductIds(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	products, err := cache.Call(cache.KeyVpsProducts, cache.DefaultTTL, func() ([]api.Product, error) {
+		return apiClient().ListVpsProducts(cmd.Context())
+	})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var comps []string
+	for _, p := range products {
+		id := strconv.Itoa(p.Id)
+		if strings.HasPrefix(id, toComplete) {
+			comps = append(comps, fmt.Sprintf("%s\t%s", id, p.Name))
+		}
 	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
 }
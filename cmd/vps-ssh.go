@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"net/netip"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var (
+	sshIPv6     bool
+	sshNetwork  string
+	sshUser     string
+	sshPort     int
+	sshIdentity string
+)
+
+// exitStatus is the exit code of a program oh ran in the foreground, such as ssh.
+// The program has already reported the problem, so oh exits with the same code silently.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("exit status %d", int(e))
+}
+
+var vpsSshCmd = &cobra.Command{
+	Use:   "ssh <vps> [-- <command>...]",
+	Short: "Connect to a VPS with ssh",
+	Long: `Runs the system ssh against the IPv4 address of the VPS, its IPv6 address with -6, or its
+address on an attached network with --network. A command after -- is run on the server instead
+of a login shell, and oh exits with its exit status.
+
+The user, port and identity file default to ssh.user, ssh.port and ssh.identity_file in the
+config, which can be set per profile. The ssh program is ssh.command, or $OH_SSH if set.
+
+` + serverRefHelp,
+	Example: `  oh vps ssh web-01
+  oh vps ssh web-01 -6 -- uptime
+  oh vps ssh 42 --network backend -l deploy`,
+	SilenceUsage:      true,
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeVpsIds,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if dash := cmd.ArgsLenAtDash(); dash > 1 || (dash == -1 && len(args) > 1) {
+			return fmt.Errorf("only one VPS expected, put the remote command after --")
+		}
+
+		host, err := sshHost(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		var sshArgs []string
+		if port := sshSetting(cmd, "port", "port", strconv.Itoa(sshPort)); port != "" && port != "0" {
+			sshArgs = append(sshArgs, "-p", port)
+		}
+		if identity := sshSetting(cmd, "identity", "identity_file", sshIdentity); identity != "" {
+			sshArgs = append(sshArgs, "-i", identity)
+		}
+		sshArgs = append(sshArgs, "--", sshTarget(cmd, host))
+		sshArgs = append(sshArgs, args[1:]...)
+
+		return runForeground(sshProgram("command", "OH_SSH", "ssh"), sshArgs)
+	},
+}
+
+var vpsScpCmd = &cobra.Command{
+	Use:   "scp <source>... <target>",
+	Short: "Copy files to or from a VPS with scp",
+	Long: `Runs the system scp, with <vps>:<path> standing for a path on a VPS. The VPS is resolved
+to an address the same way as for 'oh vps ssh', and the same user, port and identity file
+defaults apply. The scp program is ssh.scp_command, or $OH_SCP if set.`,
+	Example: `  oh vps scp ./app.tar.gz web-01:/tmp/
+  oh vps scp -6 web-01:/var/log/syslog .`,
+	SilenceUsage: true,
+	Args:         cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var scpArgs []string
+		if port := sshSetting(cmd, "port", "port", strconv.Itoa(sshPort)); port != "" && port != "0" {
+			scpArgs = append(scpArgs, "-P", port)
+		}
+		if identity := sshSetting(cmd, "identity", "identity_file", sshIdentity); identity != "" {
+			scpArgs = append(scpArgs, "-i", identity)
+		}
+		scpArgs = append(scpArgs, "--")
+
+		remote := false
+		for _, arg := range args {
+			ref, path, ok := remotePath(arg)
+			if !ok {
+				scpArgs = append(scpArgs, arg)
+				continue
+			}
+			host, err := sshHost(cmd.Context(), ref)
+			if err != nil {
+				return err
+			}
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+			scpArgs = append(scpArgs, sshTarget(cmd, host)+":"+path)
+			remote = true
+		}
+		if !remote {
+			return fmt.Errorf("none of the paths is on a VPS, use <vps>:<path>")
+		}
+
+		return runForeground(sshProgram("scp_command", "OH_SCP", "scp"), scpArgs)
+	},
+}
+
+// sshHost resolves ref to the address to connect to: the IPv4 or, with -6, the IPv6
+// address of the server, or of its attachment to the --network
+func sshHost(ctx context.Context, ref string) (string, error) {
+	vpsId, err := resolveServerId(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+
+	family := "IPv4"
+	if sshIPv6 {
+		family = "IPv6"
+	}
+
+	var ip, where string
+	if sshNetwork == "" {
+		server, err := apiClient().GetVirtualServer(ctx, vpsId)
+		if err != nil {
+			return "", err
+		}
+		ip, where = server.IPv4, fmt.Sprintf("VPS %d", vpsId)
+		if sshIPv6 {
+			ip = server.IPv6
+		}
+	} else {
+		// not cached: an attachment made a moment ago should be usable right away
+		attached, err := apiClient().ListAttachedVirtualNetworks(ctx, vpsId)
+		if err != nil {
+			return "", err
+		}
+		network, err := findAttachedNetwork(attached, sshNetwork)
+		if err != nil {
+			return "", fmt.Errorf("VPS %d: %w", vpsId, err)
+		}
+		ip, where = network.IPv4, fmt.Sprintf("VPS %d on network %s", vpsId, network.Id)
+		if sshIPv6 {
+			ip = network.IPv6
+		}
+	}
+
+	if ip == "" {
+		return "", fmt.Errorf("%s has no %s address", where, family)
+	}
+	// the API may hand out the address with its prefix length
+	if prefix, err := netip.ParsePrefix(ip); err == nil {
+		ip = prefix.Addr().String()
+	}
+	return ip, nil
+}
+
+// findAttachedNetwork finds the network by ID or by exact name
+func findAttachedNetwork(attached []api.AttachedNetwork, ref string) (api.AttachedNetwork, error) {
+	var byName []api.AttachedNetwork
+	for _, n := range attached {
+		if n.Id == ref {
+			return n, nil
+		}
+		if n.Name == ref {
+			byName = append(byName, n)
+		}
+	}
+	switch len(byName) {
+	case 1:
+		return byName[0], nil
+	case 0:
+		return api.AttachedNetwork{}, fmt.Errorf("network %q is not attached", ref)
+	default:
+		return api.AttachedNetwork{}, fmt.Errorf("%d attached networks are named %q, use the ID", len(byName), ref)
+	}
+}
+
+// remotePath splits <vps>:<path> the way scp tells remote from local paths:
+// a colon before any slash
+func remotePath(arg string) (string, string, bool) {
+	ref, path, ok := strings.Cut(arg, ":")
+	if !ok || ref == "" || strings.Contains(ref, "/") {
+		return "", "", false
+	}
+	return ref, path, true
+}
+
+// sshTarget returns user@host, or just host when no user is configured
+func sshTarget(cmd *cobra.Command, host string) string {
+	if user := sshSetting(cmd, "user", "user", sshUser); user != "" {
+		return user + "@" + host
+	}
+	return host
+}
+
+// sshSetting returns the flag's value if it was given, and the ssh.<key> setting of the
+// active profile otherwise
+func sshSetting(cmd *cobra.Command, flag, key, value string) string {
+	if cmd.Flags().Changed(flag) {
+		return value
+	}
+	return viper.GetString("ssh." + key)
+}
+
+// sshProgram returns the program to run: the environment variable, the ssh.* setting,
+// or the default found on the PATH
+func sshProgram(key, env, def string) string {
+	if p := os.Getenv(env); p != "" {
+		return p
+	}
+	if p := viper.GetString("ssh." + key); p != "" {
+		return p
+	}
+	return def
+}
+
+// runForeground runs the program attached to the terminal and returns its exit status
+func runForeground(program string, args []string) error {
+	verbosef("running %s %s", program, strings.Join(args, " "))
+
+	c := exec.Command(program, args...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err := c.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitStatus(exitErr.ExitCode())
+	}
+	if err != nil {
+		return fmt.Errorf("cannot run %s: %w", program, err)
+	}
+	return nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{vpsSshCmd, vpsScpCmd} {
+		c.Flags().BoolVarP(&sshIPv6, "ipv6", "6", false, "Connect to the IPv6 address")
+		c.Flags().StringVar(&sshNetwork, "network", "", "Connect to the address on this attached network (ID or name)")
+		c.Flags().StringVarP(&sshUser, "user", "l", "", "User to log in as (default ssh.user from the config)")
+		c.Flags().IntVarP(&sshPort, "port", "p", 0, "Port to connect to (default ssh.port from the config)")
+		c.Flags().StringVarP(&sshIdentity, "identity", "i", "", "Identity file (default ssh.identity_file from the config)")
+		_ = c.RegisterFlagCompletionFunc("network", completeSshNetworks)
+		vpsCmd.AddCommand(c)
+	}
+}
+
+// completeSshNetworks completes the networks attached to the VPS given as first argument
+func completeSshNetworks(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 || cmd == vpsScpCmd {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	vpsId, err := resolveServerId(cmd.Context(), args[0])
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	attached, err := apiClient().ListAttachedVirtualNetworks(cmd.Context(), vpsId)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var comps []string
+	for _, n := range attached {
+		if strings.HasPrefix(n.Id, toComplete) {
+			comps = append(comps, fmt.Sprintf("%s\t%s", n.Id, n.Name))
+		}
+	}
+	return comps, cobra.ShellCompDirectiveNoFileComp
+}
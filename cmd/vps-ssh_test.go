@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// stubProgram writes a script that records its arguments, one per line, and exits
+// with $STUB_EXIT, and points env at it
+func stubProgram(t *testing.T, env string) func() []string {
+	t.Helper()
+	dir := t.TempDir()
+	args, program := filepath.Join(dir, "args"), filepath.Join(dir, "stub")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > \"" + args + "\"\nexit ${STUB_EXIT:-0}\n"
+	if err := os.WriteFile(program, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(env, program)
+
+	return func() []string {
+		t.Helper()
+		b, err := os.ReadFile(args)
+		if err != nil {
+			t.Fatalf("%s was not run: %v", env, err)
+		}
+		defer os.Remove(args)
+		return strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	}
+}
+
+func TestVpsSsh(t *testing.T) {
+	env := newTestEnv(t, nil)
+	argv := stubProgram(t, "OH_SSH")
+	env.writeConfig("ssh:\n  user: deploy\n  port: 2222\n")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"web-01"}, []string{"-p", "2222", "--", "deploy@192.0.2.11"}},
+		{[]string{"web-01", "-6", "-l", "root", "-i", "/keys/id", "--", "uptime", "-p"},
+			[]string{"-p", "2222", "-i", "/keys/id", "--", "root@2001:db8::11", "uptime", "-p"}},
+		{[]string{"103", "-p", "22"}, []string{"-p", "22", "--", "deploy@192.0.2.21"}},
+		{[]string{"web-02", "--network", "net-a"}, []string{"-p", "2222", "--", "deploy@10.0.0.20"}},
+	}
+	env.mustRun("vps", "network", "attach", "web-02", "--network-id", "net-a", "--ipv4", "10.0.0.20")
+	for _, tt := range tests {
+		env.mustRun(append([]string{"vps", "ssh"}, tt.args...)...)
+		if got := argv(); !slices.Equal(got, tt.want) {
+			t.Errorf("vps ssh %v ran ssh %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	t.Setenv("STUB_EXIT", "3")
+	if r := env.run("vps", "ssh", "web-01", "--", "false"); r.code != 3 || r.stderr != "" {
+		t.Errorf("remote command failing with 3: exit %d, stderr %q", r.code, r.stderr)
+	}
+	argv()
+
+	for _, args := range [][]string{{"web-01", "web-02"}, {"web-01", "web-02", "--", "uptime"}} {
+		if r := env.run(append([]string{"vps", "ssh"}, args...)...); r.code != 1 || !strings.Contains(r.stderr, "only one VPS expected") {
+			t.Errorf("vps ssh %v: exit %d, stderr %q", args, r.code, r.stderr)
+		}
+	}
+	if r := env.run("vps", "ssh", "web-01", "--network", "nope"); r.code != 1 || !strings.Contains(r.stderr, `VPS 101: network "nope" is not attached`) {
+		t.Errorf("unknown network: exit %d, stderr %q", r.code, r.stderr)
+	}
+}
+
+func TestVpsScp(t *testing.T) {
+	env := newTestEnv(t, nil)
+	argv := stubProgram(t, "OH_SCP")
+	env.writeConfig("ssh:\n  user: deploy\n")
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"./app.tar.gz", "web-01:/tmp/"}, []string{"--", "./app.tar.gz", "deploy@192.0.2.11:/tmp/"}},
+		{[]string{"-6", "-p", "2222", "web-01:/var/log/syslog", "db-01:"},
+			[]string{"-P", "2222", "--", "deploy@[2001:db8::11]:/var/log/syslog", "deploy@[2001:db8::21]:"}},
+		{[]string{"-i", "/keys/id", "-l", "root", "./dir/a:b", "102:b"}, []string{"-i", "/keys/id", "--", "./dir/a:b", "root@192.0.2.12:b"}},
+	}
+	for _, tt := range tests {
+		env.mustRun(append([]string{"vps", "scp"}, tt.args...)...)
+		if got := argv(); !slices.Equal(got, tt.want) {
+			t.Errorf("vps scp %v ran scp %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	t.Setenv("STUB_EXIT", "1")
+	if r := env.run("vps", "scp", "a", "web-01:"); r.code != 1 || r.stderr != "" {
+		t.Errorf("scp failing with 1: exit %d, stderr %q", r.code, r.stderr)
+	}
+	argv()
+
+	if r := env.run("vps", "scp", "a", "./b:c"); r.code != 1 || !strings.Contains(r.stderr, "none of the paths is on a VPS") {
+		t.Errorf("local paths only: exit %d, stderr %q", r.code, r.stderr)
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/ui"
+	"github.com/spf13/cobra"
+	"slices"
+	"strings"
+	"time"
+)
+
+const defaultWaitTimeout = 10 * time.Minute
+
+var (
+	waitFor        string
+	waitVpsTimeout time.Duration
+	// waitEnabled and waitTimeout are --wait and --wait-timeout of state-changing commands
+	waitEnabled bool
+	waitTimeout time.Duration
+)
+
+var waitStatuses = []string{"active", "stopped", api.StatusDeleted}
+
+var waitVpsCmd = &cobra.Command{
+	Use:   "wait <vps>",
+	Short: "Wait until a VPS reaches a status",
+	Long:  `Polls the VPS with increasing intervals until it reaches the requested status, or until the timeout expires. Use status=deleted to wait until the VPS is gone.`,
+	Example: `  # wait until the server is powered off
+  oh vps wait 42 --for status=stopped
+
+  # give a freshly ordered server 20 minutes to come up
+  oh vps wait 42 --for status=active --timeout 20m`,
+	Args:              validateSingleVpsIdArg,
+	ValidArgsFunction: completeVpsIds,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, status, ok := strings.Cut(waitFor, "=")
+		if !ok || key != "status" || !slices.Contains(waitStatuses, status) {
+			return fmt.Errorf("invalid --for %q; expected status=<%s>", waitFor, strings.Join(waitStatuses, "|"))
+		}
+
+		serverId, err := resolveServerId(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		server, err := waitForStatus(cmd.Context(), serverId, status, "", waitVpsTimeout)
+		if err != nil {
+			return err
+		}
+
+		if status == api.StatusDeleted && tableOutput(cmd) {
+			cmd.Printf("Server %d is deleted\n", serverId)
+			return nil
+		}
+		return printItem(cmd, server, serverColumns()...)
+	},
+}
+
+func init() {
+	waitVpsCmd.Flags().StringVar(&waitFor, "for", "status=active", "Condition to wait for, status=<status>")
+	waitVpsCmd.RegisterFlagCompletionFunc("for", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		var comps []string
+		for _, s := range waitStatuses {
+			comps = append(comps, "status="+s)
+		}
+		return comps, cobra.ShellCompDirectiveNoFileComp
+	})
+	waitVpsCmd.Flags().DurationVar(&waitVpsTimeout, "timeout", defaultWaitTimeout, "Give up waiting after this duration")
+
+	vpsCmd.AddCommand(waitVpsCmd)
+}
+
+// addWaitFlags adds --wait and --wait-timeout to a state-changing command
+func addWaitFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&waitEnabled, "wait", false, "Wait until the change has been applied")
+	cmd.Flags().DurationVar(&waitTimeout, "wait-timeout", defaultWaitTimeout, "Give up waiting after this duration")
+}
+
+// waitForStatus polls the server until it has the given status, showing a spinner on a terminal.
+// from is the status before the change, which the server has to leave first, or empty.
+func waitForStatus(ctx context.Context, serverId int, status, from string, timeout time.Duration) (api.CloudServer, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	spinner := ui.StartSpinner(fmt.Sprintf("Waiting for server %d to become %s", serverId, status))
+	defer spinner.Stop()
+
+	opts := api.DefaultWaitOptions
+	opts.From = from
+	opts.OnPoll = func(observed string) {
+		spinner.Update(fmt.Sprintf("Waiting for server %d to become %s (currently %s)", serverId, status, observed))
+	}
+	return apiClient().WaitForStatus(ctx, serverId, status, opts)
+}
+
+// waitForNetwork polls the attached networks of the server until the network is attached or detached
+func waitForNetwork(ctx context.Context, serverId int, networkId string, attached bool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	want := "attached to"
+	if !attached {
+		want = "detached from"
+	}
+	spinner := ui.StartSpinner(fmt.Sprintf("Waiting for network %s to be %s server %d", networkId, want, serverId))
+	defer spinner.Stop()
+
+	return apiClient().WaitForNetwork(ctx, serverId, networkId, attached, api.DefaultWaitOptions)
+}
@@ -12,37 +12,46 @@ import (
 var vpsCmd = &cobra.Command{
 	Use:   "vps",
 	Short: "Commands to manipulate your Virtual Servers",
-	Long:  `Configure and control your Virtual Server instances`,
+	Long: `Configure and control your Virtual Server instances.
+
+` + serverRefHelp,
 }
 
 var validateSingleVpsIdArg = func(cmd *cobra.Command, args []string) error {
 	switch len(args) {
 	case 0:
-		return fmt.Errorf("you must specify the VPS Id")
+		return fmt.Errorf("you must specify the VPS by ID, name, glob or filter")
 	case 1:
 		return nil
 	default:
-		return fmt.Errorf("only one positional argument expected (the VPS ID), got %d", len(args))
+		return fmt.Errorf("only one positional argument expected (the VPS), got %d", len(args))
 	}
 }
 
 func init() {
+	vpsCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Do not ask for confirmation before destructive actions")
 	rootCmd.AddCommand(vpsCmd)
 }
 
 func completeVpsIds(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	// only complete the first positional (<vps-id>)
+	// only complete the first positional (<vps>)
 	if len(args) != 0 {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
 	vpsList, err := cache.Call(cache.KeyCloudServers, cache.DefaultTTL, func() ([]api.CloudServer, error) {
-		return api.ListCloudServers()
+		return apiClient().ListCloudServers(cmd.Context())
 	})
 
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveError
 	}
+	// suggest only the servers matching the --filter of the command, if it has one
+	if filters, err := cmd.Flags().GetStringArray("filter"); err == nil {
+		if vpsList, err = filterItems(vpsList, filters); err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+	}
 
 	var comps []string
 	for _, v := range vpsList {
@@ -51,6 +60,12 @@ func completeVpsIds(cmd *cobra.Command, args []string, toComplete string) ([]str
 			comps = append(comps, fmt.Sprintf("%s\t%s", id, v.Name))
 		}
 	}
+	for _, v := range vpsList {
+		// names only once something was typed, to keep the initial list short
+		if toComplete != "" && v.Name != "" && strings.HasPrefix(v.Name, toComplete) {
+			comps = append(comps, fmt.Sprintf("%s\t%d", v.Name, v.Id))
+		}
+	}
 
 	return comps, cobra.ShellCompDirectiveNoFileComp
 }
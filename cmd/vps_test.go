@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/edvin/oh/api"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVpsList(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.mustRun("vps", "list")
+	for _, want := range []string{"Id", "Status", "web-01", "web-02", "db-01", "stopped"} {
+		if !strings.Contains(r.stdout, want) {
+			t.Errorf("table does not contain %q:\n%s", want, r.stdout)
+		}
+	}
+
+	r = env.mustRun("vps", "list", "-o", "json")
+	var servers []api.CloudServer
+	if err := json.Unmarshal([]byte(r.stdout), &servers); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+	}
+	if len(servers) != 3 {
+		t.Errorf("got %d servers, want 3", len(servers))
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--filter", "status=stopped"}, "103\n"},
+		{[]string{"--filter", "name~^web", "--sort-by", "-id"}, "102\n101\n"},
+		{[]string{"--filter", "ipv4 in 192.0.2.0/28"}, "101\n102\n"},
+		{[]string{"--filter", "image.osDistro=debian"}, "103\n"},
+	}
+	for _, tt := range tests {
+		r := env.mustRun(append([]string{"vps", "list", "-o", "name"}, tt.args...)...)
+		if r.stdout != tt.want {
+			t.Errorf("vps list %v = %q, want %q", tt.args, r.stdout, tt.want)
+		}
+	}
+
+	if r := env.run("vps", "list", "--filter", "nope=1"); r.code != 1 || !strings.Contains(r.stderr, "unknown field") {
+		t.Errorf("unknown filter field: exit %d, stderr %q", r.code, r.stderr)
+	}
+}
+
+func TestVpsGet(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	for _, ref := range []string{"102", "web-02", "status=active,ipv4=192.0.2.12", "name~-02$", "ipv4 in 192.0.2.12/32,status!=stopped"} {
+		r := env.mustRun("vps", "get", ref, "-o", "json")
+		var server api.CloudServer
+		if err := json.Unmarshal([]byte(r.stdout), &server); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+		}
+		if server.Id != 102 {
+			t.Errorf("vps get %s returned server %d, want 102", ref, server.Id)
+		}
+	}
+
+	if r := env.mustRun("vps", "get", "db-01", "-o", "jsonpath={.image.osDistro}"); r.stdout != "debian\n" {
+		t.Errorf("jsonpath printed %q", r.stdout)
+	}
+	if r := env.run("vps", "get", "db-01", "-o", "jsonpath={.nope}"); r.code != 1 || !strings.Contains(r.stderr, "nope is not found") {
+		t.Errorf("jsonpath of a missing key: exit %d, stderr %q", r.code, r.stderr)
+	}
+
+	r := env.mustRun("vps", "get", "db-01")
+	if !strings.Contains(r.stdout, "Name: db-01") || !strings.Contains(r.stdout, "Status: stopped") {
+		t.Errorf("unexpected form:\n%s", r.stdout)
+	}
+
+	errors := []struct {
+		ref  string
+		want string
+	}{
+		{"web-*", "ambiguous"},
+		{"nope", `no VPS matches "nope"`},
+		{"999", "404"},
+		{"status=active", "ambiguous"},
+		{"nope=1", "unknown field"},
+		{"name~(", "invalid filter"},
+	}
+	for _, tt := range errors {
+		r := env.run("vps", "get", tt.ref)
+		if r.code != 1 || !strings.Contains(r.stderr, tt.want) {
+			t.Errorf("vps get %s: exit %d, stderr %q, want %q", tt.ref, r.code, r.stderr, tt.want)
+		}
+	}
+}
+
+func TestVpsExecute(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.mustRun("vps", "execute", "web-01", "soft-reboot")
+	if !strings.Contains(r.stdout, "soft-reboot scheduled") {
+		t.Errorf("unexpected output:\n%s", r.stdout)
+	}
+
+	// power-off must be confirmed, and stdin is not a terminal in tests
+	r = env.run("vps", "execute", "web-01", "power-off")
+	if r.code != 1 || !strings.Contains(r.stderr, "--yes") {
+		t.Errorf("unconfirmed power-off: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if status := env.status(101); status != "active" {
+		t.Errorf("unconfirmed power-off changed the status to %s", status)
+	}
+
+	env.mustRun("vps", "execute", "web-01", "power-off", "--yes")
+	if status := env.status(101); status != "stopped" {
+		t.Errorf("status after power-off is %s, want stopped", status)
+	}
+
+	// reset arguments are checked before the confirmation, which would fail here
+	resets := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--image-id", "2", "--name", "web-01"}, "password"},
+		{[]string{"--image-id", "2", "--name", "web-01", "--password", "s3cret", "--user-data", "missing.yaml"}, "cloud_init"},
+	}
+	for _, tt := range resets {
+		r := env.run(append([]string{"vps", "execute", "web-01", "reset"}, tt.args...)...)
+		if r.code != 1 || !strings.Contains(r.stderr, tt.want) || strings.Contains(r.stderr, "--yes") {
+			t.Errorf("reset %v: exit %d, stderr %q, want %q", tt.args, r.code, r.stderr, tt.want)
+		}
+	}
+
+	if r := env.run("vps", "execute", "web-01", "explode"); r.code != 1 || !strings.Contains(r.stderr, "invalid action") {
+		t.Errorf("invalid action: exit %d, stderr %q", r.code, r.stderr)
+	}
+}
+
+func TestVpsExecuteBulk(t *testing.T) {
+	// soft-reboot of web-02 fails
+	env := newTestEnv(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost && r.URL.Path == "/servers/102/soft-reboot" {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"code":409,"message":"Conflict","details":{"reason":"server is busy"}}`))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	r := env.mustRun("vps", "execute", "--all", "power-on", "-o", "json")
+	var results []bulkResult
+	if err := json.Unmarshal([]byte(r.stdout), &results); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+	}
+	if len(results) != 3 {
+		t.Errorf("got %d results, want 3", len(results))
+	}
+	if status := env.status(103); status != "active" {
+		t.Errorf("status of db-01 after power-on is %s, want active", status)
+	}
+
+	r = env.mustRun("vps", "execute", "image.osDistro~debian", "soft-reboot", "-o", "name")
+	if r.stdout != "103\n" {
+		t.Errorf("soft-reboot of the debian servers ran on %q", r.stdout)
+	}
+
+	r = env.mustRun("vps", "execute", "--all", "--filter", "name~^web", "power-off", "--yes", "-o", "name")
+	if r.stdout != "101\n102\n" {
+		t.Errorf("power-off of the web servers ran on %q", r.stdout)
+	}
+	if status := env.status(103); status != "active" {
+		t.Errorf("--filter did not spare db-01, its status is %s", status)
+	}
+
+	r = env.run("vps", "execute", "web-*", "soft-reboot", "--continue-on-error", "-o", "csv")
+	if r.code != 1 || !strings.Contains(r.stderr, "1 of 2 servers failed") {
+		t.Errorf("failed bulk action: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if !strings.Contains(r.stdout, "101,web-01,ok") || !strings.Contains(r.stdout, "server is busy") {
+		t.Errorf("unexpected results:\n%s", r.stdout)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.mustRun("--dry-run", "vps", "execute", "web-01", "power-off")
+	if !strings.Contains(r.stdout, "POST ") || !strings.Contains(r.stdout, "/servers/101/power-off") {
+		t.Errorf("dry run did not print the request:\n%s", r.stdout)
+	}
+	if strings.Contains(r.stdout, "test-token") {
+		t.Errorf("dry run printed the token:\n%s", r.stdout)
+	}
+
+	r = env.mustRun("--dry-run", "vps", "execute", "--all", "soft-reboot", "-o", "csv")
+	if strings.Count(r.stdout, "dry run, not sent") != 3 {
+		t.Errorf("bulk dry run did not report every server:\n%s", r.stdout)
+	}
+
+	r = env.mustRun("--dry-run", "vps", "execute", "web-01", "reset", "--image-id", "2", "--name", "web-01", "--password", "s3cret")
+	if !strings.Contains(r.stdout, "\n"+`{"imageId":2,"name":"web-01","password":"<redacted>"}`+"\n") {
+		t.Errorf("dry run did not print the body as it would be sent, without the password:\n%s", r.stdout)
+	}
+
+	for _, req := range env.requests() {
+		if req.Method != http.MethodGet {
+			t.Errorf("dry run sent %s %s", req.Method, req.Path)
+		}
+	}
+	if status := env.status(101); status != "active" {
+		t.Errorf("dry run changed the status to %s", status)
+	}
+}
+
+func TestUnauthorized(t *testing.T) {
+	env := newTestEnv(t, nil)
+	t.Setenv("TOKEN", "wrong")
+
+	r := env.run("vps", "list")
+	if r.code != 1 || !strings.Contains(r.stderr, "401") {
+		t.Errorf("wrong token: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if r.stdout != "" {
+		t.Errorf("wrong token printed to stdout: %q", r.stdout)
+	}
+}
+
+func TestVpsExecuteWait(t *testing.T) {
+	opts := api.DefaultWaitOptions
+	t.Cleanup(func() { api.DefaultWaitOptions = opts })
+	api.DefaultWaitOptions = api.WaitOptions{Interval: time.Millisecond, MaxInterval: time.Millisecond, Settle: 50 * time.Millisecond}
+
+	// web-01 reboots for one poll after a soft-reboot, web-02 too quickly to be seen
+	var env *testEnv
+	env = newTestEnv(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			env.fake.Lock()
+			defer env.fake.Unlock()
+			switch s := env.fake.Servers[101]; {
+			case r.Method == http.MethodPost && r.URL.Path == "/servers/101/soft-reboot":
+				s.Status = "rebooting"
+			case r.Method == http.MethodGet && r.URL.Path == "/servers/101" && s.Status == "rebooting":
+				s.Status = "active"
+			}
+		})
+	})
+	polls := func(id string) int {
+		n, posted := 0, false
+		for _, req := range env.requests() {
+			switch {
+			case req.Method == http.MethodPost && strings.HasPrefix(req.Path, "/servers/"+id+"/"):
+				posted = true
+			case req.Method == http.MethodGet && req.Path == "/servers/"+id && posted:
+				n++
+			}
+		}
+		return n
+	}
+
+	env.mustRun("vps", "execute", "web-01", "soft-reboot", "--wait")
+	if n := polls("101"); n != 2 {
+		t.Errorf("waited for web-01 with %d polls, want 2: one while rebooting and one when active", n)
+	}
+
+	start := time.Now()
+	env.mustRun("vps", "execute", "web-02", "soft-reboot", "--wait")
+	if d := time.Since(start); d < api.DefaultWaitOptions.Settle {
+		t.Errorf("the wait for web-02 returned after %s, before the status could change", d)
+	}
+
+	// power-off leaves active at once
+	r := env.mustRun("vps", "execute", "--all", "--filter", "status=active", "power-off", "--yes", "--wait", "-o", "csv")
+	if !strings.Contains(r.stdout, "now stopped") {
+		t.Errorf("unexpected results:\n%s", r.stdout)
+	}
+}
+
+func TestVpsWait(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.mustRun("vps", "wait", "db-01", "--for", "status=stopped", "-o", "name")
+	if r.stdout != "103\n" {
+		t.Errorf("wait printed %q", r.stdout)
+	}
+
+	for _, cond := range []string{"status=actve", "status=", "name=web-01", "active"} {
+		r := env.run("vps", "wait", "db-01", "--for", cond, "--timeout", "1s")
+		if r.code != 1 || !strings.Contains(r.stderr, "expected status=<active|stopped|deleted>") {
+			t.Errorf("--for %s: exit %d, stderr %q", cond, r.code, r.stderr)
+		}
+	}
+}
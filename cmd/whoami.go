@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/edvin/oh/api"
+	"github.com/edvin/oh/config"
+	"github.com/edvin/oh/ui"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type whoami struct {
+	CustomerId   int    `json:"customerId"`
+	CustomerName string `json:"customerName"`
+	Profile      string `json:"profile"`
+	BaseURL      string `json:"baseUrl"`
+	TokenSource  string `json:"tokenSource"`
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:               "whoami",
+	Short:             "Show the customer behind the API token",
+	Long:              `Verifies the API token and shows the customer it belongs to, the base URL in use and where the token was read from. Exits non-zero if the token is invalid or expired.`,
+	ValidArgsFunction: NoArgs,
+	SilenceUsage:      true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		customer, err := apiClient().GetCustomer(cmd.Context())
+		if err != nil {
+			if api.IsUnauthorized(err) {
+				return fmt.Errorf("the token from %s is invalid or expired: %w", tokenSourceName(), err)
+			}
+			return err
+		}
+
+		me := whoami{
+			CustomerId:   customer.Id,
+			CustomerName: customer.Name,
+			Profile:      config.Profile(),
+			BaseURL:      viper.GetString("base_url"),
+			TokenSource:  tokenSourceName(),
+		}
+
+		return printItem(cmd, me, whoamiColumns()...)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
+
+func whoamiColumns() []ui.TableColumn[whoami] {
+	return []ui.TableColumn[whoami]{
+		ui.Column("Customer Id", func(w whoami) int { return w.CustomerId }),
+		ui.Column("Customer", func(w whoami) string { return w.CustomerName }),
+		ui.Column("Profile", func(w whoami) string { return w.Profile }),
+		ui.Column("Base URL", func(w whoami) string { return w.BaseURL }),
+		ui.Column("Token Source", func(w whoami) string { return w.TokenSource }),
+	}
+}
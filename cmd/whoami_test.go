@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"encoding/json"
+	"github.com/edvin/oh/api/apitest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWhoami(t *testing.T) {
+	env := newTestEnv(t, nil)
+
+	r := env.mustRun("whoami")
+	for _, want := range []string{"4711", "Example GmbH", "default", os.Getenv("BASE_URL"), "environment variable TOKEN"} {
+		if !strings.Contains(r.stdout, want) {
+			t.Errorf("whoami does not show %q:\n%s", want, r.stdout)
+		}
+	}
+
+	// the token from the config file, which is not in the output
+	os.Unsetenv("TOKEN")
+	env.writeConfig("token: " + apitest.DefaultToken + "\n")
+	r = env.mustRun("whoami", "-o", "json")
+	var me whoami
+	if err := json.Unmarshal([]byte(r.stdout), &me); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, r.stdout)
+	}
+	want := whoami{CustomerId: 4711, CustomerName: "Example GmbH", Profile: "default", BaseURL: os.Getenv("BASE_URL"), TokenSource: "config file " + env.config}
+	if me != want {
+		t.Errorf("whoami = %+v, want %+v", me, want)
+	}
+	if strings.Contains(r.stdout, apitest.DefaultToken) {
+		t.Errorf("whoami shows the token:\n%s", r.stdout)
+	}
+
+	env.writeConfig("token: expired\n")
+	r = env.run("whoami")
+	if r.code != 1 || !strings.Contains(r.stderr, "the token from config file "+env.config+" is invalid or expired") {
+		t.Errorf("expired token: exit %d, stderr %q", r.code, r.stderr)
+	}
+	if r.stdout != "" {
+		t.Errorf("expired token printed to stdout: %q", r.stdout)
+	}
+}
@@ -1,21 +1,279 @@
 package config
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 )
 
-func Save() (err error) {
-	err = viper.SafeWriteConfig()
+const (
+	// DefaultProfile is the implicit profile made up of the top-level settings
+	DefaultProfile = "default"
+
+	keyProfiles       = "profiles"
+	keyCurrentProfile = "current_profile"
+)
+
+var validProfileName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// credentialKeys are the settings that lead to a token. A profile never inherits them
+// from the default profile, which would send the default token to its base_url.
+var credentialKeys = []string{"token", "token_ref", "credential_store", "credential_helper", "credential_file"}
+
+// File returns the path of the config file in use, or the default $HOME/.oh.yaml
+func File() (string, error) {
+	if f := viper.ConfigFileUsed(); f != "" {
+		return f, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".oh.yaml"), nil
+}
+
+// Load reads the settings stored in the config file, without flags, environment or defaults
+func Load() (map[string]any, error) {
+	path, err := File()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]any{}
+	if err := yaml.Unmarshal(b, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+// Update loads the config file, applies fn and writes the result back.
+// Only what is stored in the file is written, never flags or environment variables.
+func Update(fn func(settings map[string]any) error) error {
+	settings, err := Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(settings); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(settings); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	path, err := File()
+	if err != nil {
+		return err
+	}
+	// The file holds credentials, keep it private
+	if err := os.WriteFile(path, b.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return os.Chmod(path, 0o600)
+}
+
+// Set stores a setting of the active profile in the config file and in viper
+func Set(key string, value any) error {
+	fullKey := Key(key)
+	if err := Update(func(settings map[string]any) error {
+		setPath(settings, strings.Split(fullKey, "."), value)
+		return nil
+	}); err != nil {
+		return err
+	}
+	viper.Set(key, value)
+	return nil
+}
+
+// Unset removes a setting of the active profile from the config file and from viper
+func Unset(key string) error {
+	if err := Update(func(settings map[string]any) error {
+		path := strings.Split(Key(key), ".")
+		if parent, ok := lookupMap(settings, path[:len(path)-1]); ok {
+			delete(parent, path[len(path)-1])
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	viper.Set(key, "")
+	return nil
+}
+
+// Key returns the config file key for a setting of the active profile
+func Key(key string) string {
+	if p := Profile(); p != DefaultProfile {
+		return keyProfiles + "." + p + "." + key
+	}
+	return key
+}
+
+// Profile returns the name of the active profile: the --profile flag or OH_PROFILE,
+// then current_profile from the config file, then DefaultProfile
+func Profile() string {
+	if p := viper.GetString("profile"); p != "" {
+		return p
+	}
+	if p := viper.GetString(keyCurrentProfile); p != "" {
+		return p
+	}
+	return DefaultProfile
+}
+
+// Profiles returns the names of all profiles, including DefaultProfile
+func Profiles() ([]string, error) {
+	settings, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{DefaultProfile}
+	if profiles, ok := lookupMap(settings, []string{keyProfiles}); ok {
+		for name := range profiles {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names, nil
+}
+
+// ProfileSettings returns the settings stored for the given profile
+func ProfileSettings(name string) (map[string]any, bool, error) {
+	settings, err := Load()
+	if err != nil {
+		return nil, false, err
+	}
+	if name == DefaultProfile {
+		return settings, true, nil
+	}
+	profile, ok := lookupMap(settings, []string{keyProfiles, name})
+	return profile, ok, nil
+}
+
+// ApplyProfile overlays the settings of the active profile on top of the config file.
+// Flags and environment variables still take precedence.
+func ApplyProfile() error {
+	name := Profile()
+	if name == DefaultProfile {
+		return nil
+	}
+	profile, ok, err := ProfileSettings(name)
 	if err != nil {
-		var configFileAlreadyExistsError viper.ConfigFileAlreadyExistsError
-		if errors.As(err, &configFileAlreadyExistsError) {
-			err = viper.WriteConfig()
-			if err != nil {
-				return fmt.Errorf("failed to overwrite config: %v", err)
-			}
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("profile %q does not exist, see 'oh profile list'", name)
+	}
+	for _, key := range credentialKeys {
+		if _, ok := profile[key]; !ok {
+			profile[key] = ""
 		}
 	}
+	return viper.MergeConfigMap(profile)
+}
+
+// AddProfile stores a new profile with the given settings
+func AddProfile(name string, settings map[string]any) error {
+	if err := ValidateProfileName(name); err != nil {
+		return err
+	}
+	return Update(func(all map[string]any) error {
+		if _, exists := lookupMap(all, []string{keyProfiles, name}); exists {
+			return fmt.Errorf("profile %q already exists", name)
+		}
+		setPath(all, []string{keyProfiles, name}, settings)
+		return nil
+	})
+}
+
+// RemoveProfile deletes a profile, and resets current_profile if it pointed to it
+func RemoveProfile(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("the %s profile cannot be removed", DefaultProfile)
+	}
+	return Update(func(all map[string]any) error {
+		profiles, ok := lookupMap(all, []string{keyProfiles})
+		if !ok {
+			return fmt.Errorf("profile %q does not exist", name)
+		}
+		if _, ok := profiles[name]; !ok {
+			return fmt.Errorf("profile %q does not exist", name)
+		}
+		delete(profiles, name)
+		if len(profiles) == 0 {
+			delete(all, keyProfiles)
+		}
+		if all[keyCurrentProfile] == name {
+			delete(all, keyCurrentProfile)
+		}
+		return nil
+	})
+}
+
+// UseProfile makes the given profile the current one
+func UseProfile(name string) error {
+	return Update(func(all map[string]any) error {
+		if name == DefaultProfile {
+			delete(all, keyCurrentProfile)
+			return nil
+		}
+		if _, ok := lookupMap(all, []string{keyProfiles, name}); !ok {
+			return fmt.Errorf("profile %q does not exist", name)
+		}
+		all[keyCurrentProfile] = name
+		return nil
+	})
+}
+
+func ValidateProfileName(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("%q is reserved for the top-level settings", DefaultProfile)
+	}
+	if !validProfileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
 	return nil
 }
+
+// lookupMap walks nested maps along path
+func lookupMap(m map[string]any, path []string) (map[string]any, bool) {
+	for _, p := range path {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = next
+	}
+	return m, true
+}
+
+// setPath sets value at path, creating intermediate maps as needed
+func setPath(m map[string]any, path []string, value any) {
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
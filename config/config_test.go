@@ -0,0 +1,63 @@
+package config
+
+import (
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".oh.yaml")
+	err := os.WriteFile(path, []byte(`
+token_ref: keyring:default
+credential_store: helper
+credential_helper: pass-oh
+credential_file: /tmp/default.token
+base_url: https://default.example/
+profiles:
+  staging:
+    base_url: https://staging.example/
+  own:
+    token_ref: keyring:own
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile string
+		want    map[string]string
+	}{
+		{DefaultProfile, map[string]string{"token_ref": "keyring:default", "credential_helper": "pass-oh", "base_url": "https://default.example/"}},
+		{"staging", map[string]string{"token": "", "token_ref": "", "credential_store": "", "credential_helper": "", "credential_file": "", "base_url": "https://staging.example/"}},
+		{"own", map[string]string{"token_ref": "keyring:own", "credential_helper": "", "base_url": "https://default.example/"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			viper.SetConfigFile(path)
+			viper.Set("profile", tt.profile)
+			if err := viper.ReadInConfig(); err != nil {
+				t.Fatal(err)
+			}
+			if err := ApplyProfile(); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if got := viper.GetString(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigFile(path)
+	viper.Set("profile", "nope")
+	if err := ApplyProfile(); err == nil {
+		t.Error("ApplyProfile of a missing profile did not fail")
+	}
+}
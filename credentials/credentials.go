@@ -0,0 +1,78 @@
+// Package credentials stores the API token outside the config file. The config
+// file only keeps a reference such as "keyring:default", which Open resolves
+// to the Store holding the secret.
+package credentials
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Store holds a single API token
+type Store interface {
+	// Token returns the stored token
+	Token() (string, error)
+	// Save stores the token, replacing any previous one
+	Save(token string) error
+	// Erase removes the stored token
+	Erase() error
+	// Ref returns the reference to keep in the config file
+	Ref() string
+}
+
+const (
+	KindPlain   = "plain"
+	KindKeyring = "keyring"
+	KindFile    = "file"
+	KindHelper  = "helper"
+)
+
+var Kinds = []string{KindPlain, KindKeyring, KindFile, KindHelper}
+
+// Options configure the stores created by New and Open
+type Options struct {
+	// Profile separates the tokens of several profiles in a shared store
+	Profile string
+	// Passphrase is asked for the encryption passphrase of a file store.
+	// confirm is true when a new file is written, so the user can be asked twice.
+	Passphrase func(confirm bool) (string, error)
+}
+
+// New creates a store of the given kind. arg is the helper command for KindHelper and
+// an optional path for KindFile. KindPlain is not a Store, it is handled by the config file.
+func New(kind, arg string, opts Options) (Store, error) {
+	switch kind {
+	case KindKeyring:
+		return &keyringStore{account: opts.Profile}, nil
+	case KindFile:
+		if arg == "" {
+			path, err := defaultFilePath(opts.Profile)
+			if err != nil {
+				return nil, err
+			}
+			arg = path
+		}
+		return &fileStore{path: arg, passphrase: opts.Passphrase}, nil
+	case KindHelper:
+		if strings.TrimSpace(arg) == "" {
+			return nil, fmt.Errorf("credential_helper is not set in the configuration")
+		}
+		return &helperStore{command: arg, profile: opts.Profile}, nil
+	default:
+		return nil, fmt.Errorf("unknown credential store %q; must be one of [%s]", kind, strings.Join(Kinds, ", "))
+	}
+}
+
+// Open resolves a reference returned by Store.Ref
+func Open(ref string, opts Options) (Store, error) {
+	kind, arg, ok := strings.Cut(ref, ":")
+	if !ok || kind == KindPlain {
+		return nil, fmt.Errorf("invalid token_ref %q", ref)
+	}
+	if kind == KindKeyring {
+		// the account is part of the reference
+		opts.Profile = arg
+		arg = ""
+	}
+	return New(kind, arg, opts)
+}
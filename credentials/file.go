@@ -0,0 +1,138 @@
+package credentials
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// scrypt parameters recommended for interactive logins
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// fileStore keeps the token in a file encrypted with AES-256-GCM,
+// using a key derived from a passphrase with scrypt
+type fileStore struct {
+	path       string
+	passphrase func(confirm bool) (string, error)
+}
+
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func defaultFilePath(profile string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "oh", profile+".token"), nil
+}
+
+func (s *fileStore) askPassphrase(confirm bool) (string, error) {
+	if s.passphrase == nil {
+		return "", fmt.Errorf("a passphrase is required to use the encrypted token file")
+	}
+	p, err := s.passphrase(confirm)
+	if err != nil {
+		return "", err
+	}
+	if p == "" {
+		return "", fmt.Errorf("the passphrase must not be empty")
+	}
+	return p, nil
+}
+
+func (s *fileStore) Token() (string, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("encrypted token file %s does not exist, run 'oh token' to store one", s.path)
+	}
+	if err != nil {
+		return "", err
+	}
+	var f encryptedFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return "", fmt.Errorf("encrypted token file %s is corrupt: %w", s.path, err)
+	}
+
+	passphrase, err := s.askPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(passphrase, f.Salt)
+	if err != nil {
+		return "", err
+	}
+	token, err := gcm.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot decrypt %s: wrong passphrase or corrupt file", s.path)
+	}
+	return string(token), nil
+}
+
+func (s *fileStore) Save(token string) error {
+	passphrase, err := s.askPassphrase(true)
+	if err != nil {
+		return err
+	}
+
+	f := encryptedFile{Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = gcm.Seal(nil, f.Nonce, []byte(token), nil)
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, b, 0o600)
+}
+
+func (s *fileStore) Erase() error {
+	err := os.Remove(s.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Ref() string {
+	return KindFile + ":" + s.path
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
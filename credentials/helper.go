@@ -0,0 +1,57 @@
+package credentials
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// helperStore delegates to an external program in the style of git credential helpers.
+// The program is called with "get", "store" or "erase" as its last argument. "get" prints
+// the token on stdout, "store" reads it from stdin. OH_PROFILE is set to the profile name.
+type helperStore struct {
+	command string
+	profile string
+}
+
+func (s *helperStore) run(action string, stdin string) (string, error) {
+	args := strings.Fields(s.command)
+	cmd := exec.Command(args[0], append(args[1:], action)...)
+	cmd.Env = append(os.Environ(), "OH_PROFILE="+s.profile)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stderr = os.Stderr
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("credential helper %q %s failed: %w", s.command, action, err)
+	}
+	return out.String(), nil
+}
+
+func (s *helperStore) Token() (string, error) {
+	out, err := s.run("get", "")
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(out)
+	if token == "" {
+		return "", fmt.Errorf("credential helper %q returned no token", s.command)
+	}
+	return token, nil
+}
+
+func (s *helperStore) Save(token string) error {
+	_, err := s.run("store", token+"\n")
+	return err
+}
+
+func (s *helperStore) Erase() error {
+	_, err := s.run("erase", "")
+	return err
+}
+
+func (s *helperStore) Ref() string {
+	return KindHelper + ":" + s.command
+}
@@ -0,0 +1,45 @@
+package credentials
+
+import (
+	"errors"
+	"fmt"
+	"github.com/zalando/go-keyring"
+)
+
+const keyringService = "oh"
+
+// keyringStore keeps the token in the OS keyring: Secret Service on Linux,
+// Keychain on macOS and the Credential Manager on Windows
+type keyringStore struct {
+	account string
+}
+
+func (s *keyringStore) Token() (string, error) {
+	token, err := keyring.Get(keyringService, s.account)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", fmt.Errorf("no token for %q in the OS keyring, run 'oh token' to store one", s.account)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading token from the OS keyring: %w", err)
+	}
+	return token, nil
+}
+
+func (s *keyringStore) Save(token string) error {
+	if err := keyring.Set(keyringService, s.account, token); err != nil {
+		return fmt.Errorf("storing token in the OS keyring: %w", err)
+	}
+	return nil
+}
+
+func (s *keyringStore) Erase() error {
+	err := keyring.Delete(keyringService, s.account)
+	if err != nil && !errors.Is(err, keyring.ErrNotFound) {
+		return fmt.Errorf("removing token from the OS keyring: %w", err)
+	}
+	return nil
+}
+
+func (s *keyringStore) Ref() string {
+	return KindKeyring + ":" + s.account
+}
@@ -0,0 +1,172 @@
+package ui
+
+import (
+	"cmp"
+	"fmt"
+	"github.com/charmbracelet/x/term"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// columnPadding is the space kept after the content of a table column
+const columnPadding = 2
+
+// minColumnWidth is how far fitWidths shrinks a column, besides its title
+const minColumnWidth = 6
+
+// Key returns the name a column is selected and sorted by: its title in lower case,
+// without spaces or punctuation, e.g. osdistro for "OS Distro"
+func (c TableColumn[T]) Key() string {
+	return ColumnKey(c.Title)
+}
+
+// ColumnKey normalizes a column title or a column name given by the user
+func ColumnKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// SelectColumns returns the columns named in keys, in that order. Columns chosen this
+// way are shown in tables even if they are wide.
+func SelectColumns[T any](cols []TableColumn[T], keys []string) ([]TableColumn[T], error) {
+	selected := make([]TableColumn[T], 0, len(keys))
+	for _, key := range keys {
+		i := slices.IndexFunc(cols, func(c TableColumn[T]) bool { return c.Key() == ColumnKey(key) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q, use one of %s", key, strings.Join(ColumnKeys(cols), ", "))
+		}
+		c := cols[i]
+		c.Wide = false
+		selected = append(selected, c)
+	}
+	return selected, nil
+}
+
+// SortBy returns the items sorted by the columns named in keys, e.g. name,-id. A leading
+// - sorts that column in descending order. Numbers are compared as numbers.
+func SortBy[T any](items []T, cols []TableColumn[T], keys []string) ([]T, error) {
+	type sortKey struct {
+		col  TableColumn[T]
+		desc bool
+	}
+	var sortKeys []sortKey
+	for _, key := range keys {
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		i := slices.IndexFunc(cols, func(c TableColumn[T]) bool { return c.Key() == ColumnKey(key) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown column %q to sort by, use one of %s", key, strings.Join(ColumnKeys(cols), ", "))
+		}
+		sortKeys = append(sortKeys, sortKey{col: cols[i], desc: desc})
+	}
+
+	sorted := slices.Clone(items)
+	slices.SortStableFunc(sorted, func(a, b T) int {
+		for _, k := range sortKeys {
+			c := CompareValues(k.col.raw(a), k.col.raw(b))
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+	return sorted, nil
+}
+
+// raw returns the exact value of the column for item
+func (c TableColumn[T]) raw(item T) string {
+	if c.Raw != nil {
+		return c.Raw(item)
+	}
+	return c.Value(item)
+}
+
+// CompareValues compares numbers as numbers and anything else as case-insensitive text
+func CompareValues(a, b string) int {
+	x, errA := strconv.ParseFloat(a, 64)
+	y, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		return cmp.Compare(x, y)
+	}
+	return cmp.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// ColumnKeys returns the keys of the columns
+func ColumnKeys[T any](cols []TableColumn[T]) []string {
+	keys := make([]string, len(cols))
+	for i, c := range cols {
+		keys[i] = c.Key()
+	}
+	return keys
+}
+
+// TerminalWidth returns the width of the terminal on stdout, or 0 if it is not one
+func TerminalWidth() int {
+	if !IsTerminal(os.Stdout) {
+		return 0
+	}
+	width, _, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		return 0
+	}
+	return width
+}
+
+// contentWidths returns the width of the widest value or title of each column
+func contentWidths(cols []OutputColumn, rows [][]string) []int {
+	widths := make([]int, len(cols))
+	for i, c := range cols {
+		widths[i] = runewidth.StringWidth(c.Title)
+		for _, r := range rows {
+			widths[i] = max(widths[i], runewidth.StringWidth(r[i]))
+		}
+		widths[i] += columnPadding
+	}
+	return widths
+}
+
+// fitWidths shrinks the widest columns until the table fits into total, but no column
+// below its title or minColumnWidth. A total of 0 leaves the widths as they are.
+func fitWidths(cols []OutputColumn, widths []int, total int) []int {
+	if total <= 0 {
+		return widths
+	}
+	widths = slices.Clone(widths)
+	minimum := make([]int, len(cols))
+	for i, c := range cols {
+		minimum[i] = max(runewidth.StringWidth(c.Title), minColumnWidth) + columnPadding
+	}
+
+	for sum(widths) > total {
+		widest := -1
+		for i, w := range widths {
+			if w > minimum[i] && (widest < 0 || w > widths[widest]) {
+				widest = i
+			}
+		}
+		if widest < 0 {
+			break
+		}
+		widths[widest]--
+	}
+	return widths
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
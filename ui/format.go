@@ -0,0 +1,394 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/charmbracelet/lipgloss"
+	"gopkg.in/yaml.v3"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// Output is what a formatter renders: the value as it is, for the structured formats,
+// and the same value as rows of columns, for the tabular ones
+type Output struct {
+	Value   any
+	Columns []OutputColumn
+	Rows    [][]string
+	// RawRows holds the exact values of Rows, for formats read by scripts
+	RawRows [][]string
+	// Form is set for a single item, which the table format shows as key: value lines
+	Form bool
+	// Nested holds the lists within a single item by column index, shown as tables in the form
+	Nested map[int]Output
+}
+
+// OutputColumn describes a column of Output.Rows
+type OutputColumn struct {
+	Title string
+	Wide  bool
+	// Style colors a value in tables, if set
+	Style func(value string) lipgloss.Style
+}
+
+// Formatter writes out in a format. arg is what follows the = in e.g. go-template=...
+type Formatter func(w io.Writer, arg string, out Output) error
+
+type formatter struct {
+	fn Formatter
+	// tabular formatters need Output.Columns, the others Output.Value
+	tabular bool
+	// withArg formatters are given as name=arg
+	withArg bool
+}
+
+var formatters = map[string]formatter{}
+
+// RegisterFormatter makes a format available. Tabular formatters render Output.Rows and
+// cannot be used for output that has no columns; a formatter with an argument is
+// selected as name=arg.
+func RegisterFormatter(name string, tabular, withArg bool, fn Formatter) {
+	formatters[name] = formatter{fn: fn, tabular: tabular, withArg: withArg}
+}
+
+// Formats returns the registered formats, those taking an argument with a trailing =
+func Formats() []string {
+	names := make([]string, 0, len(formatters))
+	for name, f := range formatters {
+		if f.withArg {
+			name += "="
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseFormat splits a format such as jsonpath={.id} into its name and argument,
+// and checks that the format exists
+func ParseFormat(spec string) (string, string, error) {
+	name, arg, hasArg := strings.Cut(spec, "=")
+	f, ok := formatters[name]
+	switch {
+	case !ok:
+		return "", "", fmt.Errorf("unknown output format %q, use one of %s", name, strings.Join(Formats(), ", "))
+	case f.withArg && !hasArg:
+		return "", "", fmt.Errorf("output format %s needs an argument, e.g. %s=...", name, name)
+	case !f.withArg && hasArg:
+		return "", "", fmt.Errorf("output format %s takes no argument", name)
+	}
+	return name, arg, nil
+}
+
+// Format writes out in the format given as name or name=arg
+func Format(w io.Writer, spec string, out Output) error {
+	name, arg, err := ParseFormat(spec)
+	if err != nil {
+		return err
+	}
+	f := formatters[name]
+	if f.tabular && out.Columns == nil {
+		return fmt.Errorf("output format %s is not supported by this command", name)
+	}
+	return f.fn(w, arg, out)
+}
+
+// List returns the Output of a list of items, one row per item
+func List[T any](items []T, cols ...TableColumn[T]) Output {
+	rows, rawRows := make([][]string, len(items)), make([][]string, len(items))
+	for i, item := range items {
+		rows[i], rawRows[i] = row(item, cols), rawRow(item, cols)
+	}
+	return Output{Value: items, Columns: outputColumns(cols), Rows: rows, RawRows: rawRows}
+}
+
+// Item returns the Output of a single item
+func Item[T any](item T, cols ...TableColumn[T]) Output {
+	out := Output{
+		Value:   item,
+		Columns: outputColumns(cols),
+		Rows:    [][]string{row(item, cols)},
+		RawRows: [][]string{rawRow(item, cols)},
+		Form:    true,
+	}
+	for i, c := range cols {
+		if c.Table != nil && !Raw {
+			if out.Nested == nil {
+				out.Nested = map[int]Output{}
+			}
+			out.Nested[i] = c.Table(item)
+		}
+	}
+	return out
+}
+
+func row[T any](item T, cols []TableColumn[T]) []string {
+	r := make([]string, len(cols))
+	for i, c := range cols {
+		r[i] = c.Value(item)
+	}
+	return r
+}
+
+func rawRow[T any](item T, cols []TableColumn[T]) []string {
+	r := make([]string, len(cols))
+	for i, c := range cols {
+		r[i] = c.raw(item)
+	}
+	return r
+}
+
+func outputColumns[T any](cols []TableColumn[T]) []OutputColumn {
+	out := make([]OutputColumn, len(cols))
+	for i, c := range cols {
+		out[i] = OutputColumn{Title: c.Title, Wide: c.Wide, Style: c.Style}
+	}
+	return out
+}
+
+func init() {
+	RegisterFormatter("table", true, false, func(w io.Writer, _ string, out Output) error {
+		return writeTable(w, out, false)
+	})
+	RegisterFormatter("wide", true, false, func(w io.Writer, _ string, out Output) error {
+		return writeTable(w, out, true)
+	})
+	RegisterFormatter("csv", true, false, func(w io.Writer, _ string, out Output) error {
+		return writeDelimited(w, out, ',')
+	})
+	RegisterFormatter("tsv", true, false, func(w io.Writer, _ string, out Output) error {
+		return writeDelimited(w, out, '\t')
+	})
+	RegisterFormatter("name", true, false, writeNames)
+	RegisterFormatter("json", false, false, writeJSON)
+	RegisterFormatter("yaml", false, false, writeYAML)
+	RegisterFormatter("go-template", false, true, writeGoTemplate)
+	RegisterFormatter("jsonpath", false, true, writeJSONPath)
+}
+
+// writeTable writes a list as a table and a single item as key: value lines. Columns are
+// sized to their content and shrunk to fit the terminal; the wide table has every column
+// and is never shrunk.
+func writeTable(w io.Writer, out Output, wide bool) error {
+	if out.Form {
+		return writeForm(w, out, wide)
+	}
+
+	var cols []OutputColumn
+	var idx []int
+	for i, c := range out.Columns {
+		if c.Wide && !wide {
+			continue
+		}
+		cols = append(cols, c)
+		idx = append(idx, i)
+	}
+
+	rows := make([][]string, len(out.Rows))
+	for i, r := range out.Rows {
+		rows[i] = make([]string, len(idx))
+		for j, k := range idx {
+			rows[i][j] = r[k]
+		}
+	}
+	widths := contentWidths(cols, rows)
+	if !wide {
+		widths = fitWidths(cols, widths, TerminalWidth())
+	}
+	_, err := fmt.Fprintln(w, renderTable(cols, widths, rows))
+	return err
+}
+
+// writeForm writes a single item as key: value lines, and the lists within it as
+// tables indented below their key
+func writeForm(w io.Writer, out Output, wide bool) error {
+	for i, c := range out.Columns {
+		value := out.Rows[0][i]
+		if c.Style != nil {
+			value = c.Style(value).Render(value)
+		}
+		nested, ok := out.Nested[i]
+		if !ok || len(nested.Rows) == 0 {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", c.Title, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var b bytes.Buffer
+		if err := writeTable(&b, nested, wide); err != nil {
+			return err
+		}
+		table := strings.TrimRight(b.String(), "\n")
+		if _, err := fmt.Fprintf(w, "%s:\n  %s\n", c.Title, strings.ReplaceAll(table, "\n", "\n  ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDelimited writes the exact values of a list, for scripts, as csv or tsv
+func writeDelimited(w io.Writer, out Output, comma rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = comma
+	header := make([]string, len(out.Columns))
+	for i, c := range out.Columns {
+		header[i] = c.Title
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	rows := out.RawRows
+	if rows == nil {
+		rows = out.Rows
+	}
+	for _, r := range rows {
+		if comma == '\t' {
+			// no quoting in TSV, a value just cannot hold a tab or a line break
+			r = append([]string(nil), r...)
+			for i := range r {
+				r[i] = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ").Replace(r[i])
+			}
+		}
+		if err := cw.Write(r); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeNames writes the Id column, or the first one if there is none, one value per line
+func writeNames(w io.Writer, _ string, out Output) error {
+	col := 0
+	for i, c := range out.Columns {
+		if strings.EqualFold(c.Title, "id") {
+			col = i
+			break
+		}
+	}
+	for _, r := range out.Rows {
+		if _, err := fmt.Fprintln(w, r[col]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, _ string, out Output) error {
+	b, err := json.MarshalIndent(out.Value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to JSON-encode output: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+func writeYAML(w io.Writer, _ string, out Output) error {
+	v, err := jsonValue(out.Value)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(yamlNumbers(v)); err != nil {
+		return fmt.Errorf("failed to YAML-encode output: %w", err)
+	}
+	return enc.Close()
+}
+
+func writeGoTemplate(w io.Writer, arg string, out Output) error {
+	tmpl, err := template.New("output").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"join": func(sep string, v []any) string {
+			s := make([]string, len(v))
+			for i, e := range v {
+				s[i] = fmt.Sprint(e)
+			}
+			return strings.Join(s, sep)
+		},
+	}).Parse(arg)
+	if err != nil {
+		return fmt.Errorf("invalid go-template: %w", err)
+	}
+	v, err := jsonValue(out.Value)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, v); err != nil {
+		return fmt.Errorf("go-template failed: %w", err)
+	}
+	return writeLine(w, b.Bytes())
+}
+
+func writeJSONPath(w io.Writer, arg string, out Output) error {
+	tmpl, err := ParseJSONPath(arg)
+	if err != nil {
+		return err
+	}
+	v, err := jsonValue(out.Value)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, v); err != nil {
+		return err
+	}
+	return writeLine(w, b.Bytes())
+}
+
+// yamlNumbers turns the json.Numbers in v into ints and floats, which YAML writes unquoted
+func yamlNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		for k, e := range v {
+			v[k] = yamlNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = yamlNumbers(e)
+		}
+	}
+	return v
+}
+
+// writeLine writes b with a trailing line break, so the shell prompt starts on a new line
+func writeLine(w io.Writer, b []byte) error {
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		b = append(b, '\n')
+	}
+	_, err := w.Write(b)
+	return err
+}
+
+// jsonValue returns v as decoded from its JSON encoding, so templates and paths address
+// fields by their JSON names
+func jsonValue(v any) (any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to JSON-encode output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var out any
+	if err := dec.Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to JSON-encode output: %w", err)
+	}
+	return out, nil
+}
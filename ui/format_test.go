@@ -0,0 +1,45 @@
+package ui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type disk struct {
+	Name string
+	Size Megabytes
+}
+
+func TestDelimitedFormatsAreExact(t *testing.T) {
+	disks := []disk{{"root", 20480}, {"data", 1536}}
+	cols := []TableColumn[disk]{
+		Column("Name", func(d disk) string { return d.Name }),
+		Column("Size", func(d disk) Megabytes { return d.Size }),
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"csv", "Name,Size\nroot,20480\ndata,1536\n"},
+		{"tsv", "Name\tSize\nroot\t20480\ndata\t1536\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := Format(&b, tt.format, List(disks, cols...)); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.format, b.String(), tt.want)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := Format(&b, "table", List(disks, cols...)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "20 GiB") || !strings.Contains(b.String(), "1.5 GiB") {
+		t.Errorf("table does not show sizes for people:\n%s", b.String())
+	}
+}
@@ -0,0 +1,535 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed kubectl-style JSONPath template: text with {expressions} such as
+// {.name}, {[*].id}, {..ipv4}, {[?(@.status=="active")].name} and {range [*]}...{end}.
+// Several results of one expression are separated by a space. As in kubectl, a field
+// that none of the values has, or an index out of bounds, is an error; filters and
+// recursive descent just match nothing.
+type JSONPath struct {
+	src   string
+	nodes []jpNode
+}
+
+type jpNode struct {
+	text  string // literal text, when path is nil
+	expr  string // the expression between the braces, for errors
+	path  []jpStep
+	body  []jpNode // the nodes of a range, when set
+	isLit bool
+}
+
+type jpStep struct {
+	kind   string // root, field, recurse, wildcard, index, slice, union, filter
+	names  []string
+	index  []int
+	slice  [2]*int
+	filter *jpFilter
+}
+
+type jpFilter struct {
+	path  []jpStep
+	op    string
+	value any // string, float64 or nil for an existence check
+}
+
+// ParseJSONPath parses a JSONPath template
+func ParseJSONPath(src string) (*JSONPath, error) {
+	p := &JSONPath{src: src}
+	stack := [][]jpNode{nil}
+	var ranges []jpNode
+
+	for pos := 0; pos < len(src); {
+		open := strings.IndexByte(src[pos:], '{')
+		if open < 0 {
+			stack[len(stack)-1] = append(stack[len(stack)-1], jpNode{text: src[pos:], isLit: true})
+			break
+		}
+		if open > 0 {
+			stack[len(stack)-1] = append(stack[len(stack)-1], jpNode{text: src[pos : pos+open], isLit: true})
+		}
+		start := pos + open
+		end, err := closingBrace(src, start)
+		if err != nil {
+			return nil, err
+		}
+		expr := strings.TrimSpace(src[start+1 : end])
+		pos = end + 1
+
+		switch {
+		case expr == "end":
+			if len(ranges) == 0 {
+				return nil, p.errorAt(start, "{end} without {range}")
+			}
+			r := ranges[len(ranges)-1]
+			r.body = append([]jpNode{}, stack[len(stack)-1]...)
+			ranges, stack = ranges[:len(ranges)-1], stack[:len(stack)-1]
+			stack[len(stack)-1] = append(stack[len(stack)-1], r)
+		case strings.HasPrefix(expr, "range "):
+			path, err := p.parsePath(strings.TrimSpace(strings.TrimPrefix(expr, "range ")), start)
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, jpNode{expr: expr, path: path, body: []jpNode{}})
+			stack = append(stack, nil)
+		case strings.HasPrefix(expr, `"`) || strings.HasPrefix(expr, "'"):
+			text, err := unquote(expr)
+			if err != nil {
+				return nil, p.errorAt(start, "invalid string %s", expr)
+			}
+			stack[len(stack)-1] = append(stack[len(stack)-1], jpNode{text: text, isLit: true})
+		default:
+			path, err := p.parsePath(expr, start)
+			if err != nil {
+				return nil, err
+			}
+			stack[len(stack)-1] = append(stack[len(stack)-1], jpNode{expr: expr, path: path})
+		}
+	}
+	if len(ranges) > 0 {
+		return nil, fmt.Errorf("invalid jsonpath %q: {range} without {end}", src)
+	}
+	p.nodes = stack[0]
+	return p, nil
+}
+
+// Execute writes the template applied to data, a value as decoded from JSON
+func (p *JSONPath) Execute(w io.Writer, data any) error {
+	return p.execute(w, p.nodes, data, data)
+}
+
+func (p *JSONPath) execute(w io.Writer, nodes []jpNode, root, current any) error {
+	for _, n := range nodes {
+		if n.isLit {
+			if _, err := io.WriteString(w, n.text); err != nil {
+				return err
+			}
+			continue
+		}
+		results, err := evalPath(n.path, root, current)
+		if err != nil {
+			return fmt.Errorf("jsonpath {%s}: %w", n.expr, err)
+		}
+		if n.body != nil {
+			for _, r := range results {
+				if err := p.execute(w, n.body, root, r); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		texts := make([]string, len(results))
+		for i, r := range results {
+			texts[i] = jsonText(r)
+		}
+		if _, err := io.WriteString(w, strings.Join(texts, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *JSONPath) errorAt(pos int, format string, args ...any) error {
+	return fmt.Errorf("invalid jsonpath %q at position %d: %s", p.src, pos+1, fmt.Sprintf(format, args...))
+}
+
+// closingBrace returns the index of the } closing the { at start, skipping quoted text
+func closingBrace(src string, start int) (int, error) {
+	var quote byte
+	for i := start + 1; i < len(src); i++ {
+		switch c := src[i]; {
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '}':
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid jsonpath %q at position %d: unclosed {", src, start+1)
+}
+
+// parsePath parses an expression such as $.a..b[0][*]['c','d'][?(@.e>1)]. at is the
+// position of the expression in the template, for error messages.
+func (p *JSONPath) parsePath(expr string, at int) ([]jpStep, error) {
+	var steps []jpStep
+	i := 0
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		steps = append(steps, jpStep{kind: "root"})
+		i++
+	case strings.HasPrefix(expr, "@"):
+		i++
+	}
+	for i < len(expr) {
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			i += 2
+			name, n := readName(expr[i:])
+			if n == 0 {
+				return nil, p.errorAt(at+i, "expected a field name after ..")
+			}
+			steps = append(steps, jpStep{kind: "recurse", names: []string{name}})
+			i += n
+		case expr[i] == '.':
+			i++
+			if i < len(expr) && expr[i] == '[' {
+				continue
+			}
+			name, n := readName(expr[i:])
+			switch {
+			case n == 0 && i == len(expr):
+				// a lone . is the current value
+			case n == 0:
+				return nil, p.errorAt(at+i, "expected a field name, got %q", expr[i:i+1])
+			case name == "*":
+				steps = append(steps, jpStep{kind: "wildcard"})
+			default:
+				steps = append(steps, jpStep{kind: "field", names: []string{name}})
+			}
+			i += n
+		case expr[i] == '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if strings.HasPrefix(expr[i:], "[?(") {
+				end = strings.Index(expr[i:], ")]")
+				if end >= 0 {
+					end++
+				}
+			}
+			if end < 0 {
+				return nil, p.errorAt(at+i, "unclosed [")
+			}
+			step, err := p.parseBracket(expr[i+1:i+end], at+i)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			i += end + 1
+		default:
+			return nil, p.errorAt(at+i, "unexpected %q", expr[i:i+1])
+		}
+	}
+	return steps, nil
+}
+
+func (p *JSONPath) parseBracket(inner string, at int) (jpStep, error) {
+	inner = strings.TrimSpace(inner)
+	switch {
+	case inner == "*":
+		return jpStep{kind: "wildcard"}, nil
+	case strings.HasPrefix(inner, "?(") && strings.HasSuffix(inner, ")"):
+		f, err := p.parseFilter(strings.TrimSpace(inner[2:len(inner)-1]), at)
+		return jpStep{kind: "filter", filter: f}, err
+	case strings.Contains(inner, ":"):
+		var step jpStep
+		step.kind = "slice"
+		bounds := strings.SplitN(inner, ":", 3)
+		for j := 0; j < 2; j++ {
+			b := strings.TrimSpace(bounds[j])
+			if b == "" {
+				continue
+			}
+			n, err := strconv.Atoi(b)
+			if err != nil {
+				return step, p.errorAt(at, "invalid slice bound %q", b)
+			}
+			step.slice[j] = &n
+		}
+		return step, nil
+	}
+
+	var step jpStep
+	for _, part := range strings.Split(inner, ",") {
+		part = strings.TrimSpace(part)
+		if n, err := strconv.Atoi(part); err == nil {
+			step.kind = "index"
+			step.index = append(step.index, n)
+			continue
+		}
+		name, err := unquote(part)
+		if err != nil {
+			return step, p.errorAt(at, "invalid subscript %q", part)
+		}
+		step.kind = "union"
+		step.names = append(step.names, name)
+	}
+	if step.index != nil && step.names != nil {
+		return step, p.errorAt(at, "cannot mix indexes and names in [%s]", inner)
+	}
+	return step, nil
+}
+
+func (p *JSONPath) parseFilter(expr string, at int) (*jpFilter, error) {
+	if !strings.HasPrefix(expr, "@") {
+		return nil, p.errorAt(at, "a filter must start with @, got %q", expr)
+	}
+	f := &jpFilter{}
+	left := expr
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if l, r, ok := strings.Cut(expr, op); ok {
+			left, f.op = strings.TrimSpace(l), op
+			r = strings.TrimSpace(r)
+			if s, err := unquote(r); err == nil {
+				f.value = s
+			} else if n, err := strconv.ParseFloat(r, 64); err == nil {
+				f.value = n
+			} else {
+				return nil, p.errorAt(at, "invalid value %q in filter, quote strings", r)
+			}
+			break
+		}
+	}
+	path, err := p.parsePath(left, at)
+	if err != nil {
+		return nil, err
+	}
+	f.path = path
+	return f, nil
+}
+
+func readName(s string) (string, int) {
+	if strings.HasPrefix(s, "*") {
+		return "*", 1
+	}
+	n := 0
+	for n < len(s) && (s[n] == '_' || s[n] == '-' || s[n] >= '0' && s[n] <= '9' ||
+		s[n] >= 'a' && s[n] <= 'z' || s[n] >= 'A' && s[n] <= 'Z') {
+		n++
+	}
+	return s[:n], n
+}
+
+func unquote(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		s = `"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`
+	}
+	if len(s) < 2 || s[0] != '"' {
+		return "", fmt.Errorf("not a string: %s", s)
+	}
+	return strconv.Unquote(s)
+}
+
+func evalPath(steps []jpStep, root, current any) ([]any, error) {
+	values := []any{current}
+	for _, step := range steps {
+		if step.kind == "root" {
+			values = []any{root}
+			continue
+		}
+		var next []any
+		for _, v := range values {
+			found, err := evalStep(step, root, v)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, found...)
+		}
+		if len(next) == 0 && len(values) > 0 && (step.kind == "field" || step.kind == "union") {
+			return nil, fmt.Errorf("%s is not found", strings.Join(step.names, ","))
+		}
+		values = next
+	}
+	return values, nil
+}
+
+func evalStep(step jpStep, root, v any) ([]any, error) {
+	switch step.kind {
+	case "field", "union":
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		var out []any
+		for _, name := range step.names {
+			if e, ok := m[name]; ok {
+				out = append(out, e)
+			}
+		}
+		return out, nil
+	case "wildcard":
+		return children(v), nil
+	case "recurse":
+		var out []any
+		var walk func(any)
+		walk = func(v any) {
+			if m, ok := v.(map[string]any); ok && step.names[0] != "*" {
+				if e, ok := m[step.names[0]]; ok {
+					out = append(out, e)
+				}
+			}
+			for _, c := range children(v) {
+				if step.names[0] == "*" {
+					out = append(out, c)
+				}
+				walk(c)
+			}
+		}
+		walk(v)
+		return out, nil
+	case "index":
+		a, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("cannot index %s, it is not an array", jsonKind(v))
+		}
+		var out []any
+		for _, i := range step.index {
+			j := i
+			if j < 0 {
+				j += len(a)
+			}
+			if j < 0 || j >= len(a) {
+				return nil, fmt.Errorf("array index out of bounds: index %d, length %d", i, len(a))
+			}
+			out = append(out, a[j])
+		}
+		return out, nil
+	case "slice":
+		a, ok := v.([]any)
+		if !ok {
+			return nil, nil
+		}
+		start, end := 0, len(a)
+		if step.slice[0] != nil {
+			start = clampIndex(*step.slice[0], len(a))
+		}
+		if step.slice[1] != nil {
+			end = clampIndex(*step.slice[1], len(a))
+		}
+		if start >= end {
+			return nil, nil
+		}
+		return a[start:end], nil
+	case "filter":
+		var out []any
+		for _, c := range children(v) {
+			if step.filter.match(root, c) {
+				out = append(out, c)
+			}
+		}
+		return out, nil
+	}
+	return nil, nil
+}
+
+// jsonKind names the JSON type of v for errors
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return "a number"
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	return min(max(i, 0), n)
+}
+
+// children returns the elements of an array, or the values of an object ordered by key
+func children(v any) []any {
+	switch v := v.(type) {
+	case []any:
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = v[k]
+		}
+		return out
+	}
+	return nil
+}
+
+// match reports whether v passes the filter. A missing field or index does not match.
+func (f *jpFilter) match(root, v any) bool {
+	results, err := evalPath(f.path, root, v)
+	if err != nil {
+		return false
+	}
+	if f.op == "" {
+		return len(results) > 0 && results[0] != nil && results[0] != false
+	}
+	if len(results) == 0 {
+		return false
+	}
+	switch want := f.value.(type) {
+	case string:
+		got := jsonText(results[0])
+		switch f.op {
+		case "==":
+			return got == want
+		case "!=":
+			return got != want
+		case "<":
+			return got < want
+		case "<=":
+			return got <= want
+		case ">":
+			return got > want
+		case ">=":
+			return got >= want
+		}
+	case float64:
+		got, err := strconv.ParseFloat(jsonText(results[0]), 64)
+		if err != nil {
+			return f.op == "!="
+		}
+		switch f.op {
+		case "==":
+			return got == want
+		case "!=":
+			return got != want
+		case "<":
+			return got < want
+		case "<=":
+			return got <= want
+		case ">":
+			return got > want
+		case ">=":
+			return got >= want
+		}
+	}
+	return false
+}
+
+// jsonText returns a scalar as plain text, and objects and arrays as compact JSON
+func jsonText(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case map[string]any, []any:
+		b, _ := json.Marshal(v)
+		return string(b)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,130 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const jsonPathData = `{
+  "items": [
+    {"id": 101, "name": "web-01", "status": "active", "ipv4": "192.0.2.11", "tags": ["web", "prod"], "image": {"osDistro": "ubuntu"}},
+    {"id": 102, "name": "web-02", "status": "stopped", "ipv4": "192.0.2.12", "tags": [], "image": {"osDistro": "ubuntu"}},
+    {"id": 103, "name": "db-01", "status": "active", "image": {"osDistro": "debian"}}
+  ],
+  "count": 3,
+  "empty": null
+}`
+
+func TestJSONPath(t *testing.T) {
+	data, err := jsonValue(json.RawMessage(jsonPathData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{.count}", "3"},
+		{"{$.count}", "3"},
+		{"count: {.count}", "count: 3"},
+		{"{.items[0].name}", "web-01"},
+		{"{.items[-1].name}", "db-01"},
+		{"{.items[0,2].id}", "101 103"},
+		{"{.items[*].id}", "101 102 103"},
+		{"{.items[1:].id}", "102 103"},
+		{"{.items[:1].id}", "101"},
+		{"{.items[5:].id}", ""},
+		{"{.items[0]['id','name']}", "101 web-01"},
+		{"{.items[0].tags}", `["web","prod"]`},
+		{"{.items[0].image}", `{"osDistro":"ubuntu"}`},
+		{"{.empty}", ""},
+		{"{..osDistro}", "ubuntu ubuntu debian"},
+		{"{..nothing}", ""},
+		{`{.items[?(@.status=="active")].name}`, "web-01 db-01"},
+		{`{.items[?(@.id>=102)].name}`, "web-02 db-01"},
+		{`{.items[?(@.ipv4)].name}`, "web-01 web-02"},
+		{`{.items[?(@.image.osDistro!='ubuntu')].name}`, "db-01"},
+		{`{.items[?(@.missing=="x")].name}`, ""},
+		{`{range .items[*]}{.id}{"\t"}{.status}{"\n"}{end}`, "101\tactive\n102\tstopped\n103\tactive\n"},
+		{`{range .items[?(@.tags[0]=="web")]}{.name}{end}`, "web-01"},
+		{`{"{"}{.count}{'}'}`, "{3}"},
+	}
+	for _, tt := range tests {
+		p, err := ParseJSONPath(tt.template)
+		if err != nil {
+			t.Errorf("ParseJSONPath(%s): %v", tt.template, err)
+			continue
+		}
+		var b bytes.Buffer
+		if err := p.Execute(&b, data); err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.template, b.String(), tt.want)
+		}
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	data, err := jsonValue(json.RawMessage(jsonPathData))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{.missing}", "missing is not found"},
+		{"{.items[0].missing}", "missing is not found"},
+		{"{.items[*].ipv4}", ""}, // found in some of the items
+		{"{range .items[*]}{.ipv4}{end}", "ipv4 is not found"},
+		{"{.items[3]}", "array index out of bounds: index 3, length 3"},
+		{"{.items[-4]}", "array index out of bounds: index -4, length 3"},
+		{"{.count[0]}", "cannot index a number"},
+		{"{.count.value}", "value is not found"},
+		{"{.missing[0]}", "missing is not found"},
+	}
+	for _, tt := range tests {
+		p, err := ParseJSONPath(tt.template)
+		if err != nil {
+			t.Errorf("ParseJSONPath(%s): %v", tt.template, err)
+			continue
+		}
+		err = p.Execute(&bytes.Buffer{}, data)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("%s: %v", tt.template, err)
+		case tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)):
+			t.Errorf("%s: error %v, want %q", tt.template, err, tt.want)
+		}
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"{.name", "unclosed {"},
+		{"{end}", "{end} without {range}"},
+		{"{range .items[*]}{.id}", "{range} without {end}"},
+		{"{.items[0}", "unclosed ["},
+		{"{.items[0,'a']}", "cannot mix indexes and names"},
+		{"{.items[1:x]}", "invalid slice bound"},
+		{"{.items[?(.id==1)]}", "a filter must start with @"},
+		{"{.items[?(@.name==web)]}", "quote strings"},
+		{"{.#}", "expected a field name"},
+		{"{name}", "unexpected"},
+	}
+	for _, tt := range tests {
+		_, err := ParseJSONPath(tt.template)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseJSONPath(%s): error %v, want %q", tt.template, err, tt.want)
+		}
+	}
+}
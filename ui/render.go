@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/edvin/oh/api"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Raw turns off the rendering of values for people, so columns show the exact values
+// of the API. Set by --raw.
+var Raw bool
+
+// Bytes, Megabytes and Gigabytes are sizes in the unit the API gives them in, shown
+// with a unit that keeps the number small, e.g. 2048 Megabytes as 2 GiB
+type (
+	Bytes     int64
+	Megabytes int64
+	Gigabytes int64
+)
+
+// Status is a status such as active or stopped, colored in tables
+type Status string
+
+// Render returns v as shown in tables and forms, or as it is with Raw
+func Render(v any) string {
+	if Raw {
+		return fmt.Sprint(v)
+	}
+	switch v := v.(type) {
+	case api.Size64:
+		return humanBytes(float64(v))
+	case Bytes:
+		return humanBytes(float64(v))
+	case Megabytes:
+		return humanBytes(float64(v) * (1 << 20))
+	case Gigabytes:
+		return humanBytes(float64(v) * (1 << 30))
+	case api.Date:
+		if v.IsZero() {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s)", v, daysAgo(v.Time, time.Now()))
+	case api.Timestamp:
+		if v.IsZero() {
+			return ""
+		}
+		return fmt.Sprintf("%s (%s)", v.Local().Format("2006-01-02 15:04"), ago(time.Since(v.Time)))
+	}
+	return fmt.Sprint(v)
+}
+
+// humanBytes shows a number of bytes in binary units, with a decimal below 10
+func humanBytes(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%d B", int64(n))
+	}
+	i := -1
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	s := strconv.FormatFloat(n, 'f', 0, 64)
+	if n < 10 {
+		s = strings.TrimSuffix(strconv.FormatFloat(n, 'f', 1, 64), ".0")
+	}
+	return s + " " + units[i:i+1] + "iB"
+}
+
+// daysAgo is how long ago the date t was, in days rather than hours
+func daysAgo(t, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch days := int(today.Sub(day).Hours() / 24); days {
+	case 0:
+		return "today"
+	case 1:
+		return "yesterday"
+	case -1:
+		return "tomorrow"
+	}
+	return ago(today.Sub(day))
+}
+
+// ago is a duration in words, e.g. 3 hours ago, or in 2 days if it is negative
+func ago(d time.Duration) string {
+	future := d < 0
+	if future {
+		d = -d
+	}
+
+	const day = 24 * time.Hour
+	var n int
+	var unit string
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < day:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 30*day:
+		n, unit = int(d/day), "day"
+	case d < 365*day:
+		n, unit = int(d/(30*day)), "month"
+	default:
+		n, unit = int(d/(365*day)), "year"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
+
+// statusStyle colors a status green when all is well, red when it failed or is gone,
+// and yellow when it is on its way or stopped
+func statusStyle(status string) lipgloss.Style {
+	style := lipgloss.NewStyle()
+	switch strings.ToLower(status) {
+	case "":
+		return style
+	case "active", "ok", "available":
+		return style.Foreground(lipgloss.Color("2"))
+	case "error", "failed", "deleted":
+		return style.Foreground(lipgloss.Color("1"))
+	}
+	return style.Foreground(lipgloss.Color("3"))
+}
+
+// rawJSON is the exact form of a nested value
+func rawJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("unable to marshal: %v", err)
+	}
+	return string(b)
+}
@@ -0,0 +1,70 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/mattn/go-isatty"
+	"os"
+	"sync"
+	"time"
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// Spinner shows a message with an animation on stderr while something is in progress.
+// It does nothing when stderr is not a terminal, so output stays clean in scripts.
+type Spinner struct {
+	mu   sync.Mutex
+	msg  string
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartSpinner starts a spinner showing msg
+func StartSpinner(msg string) *Spinner {
+	s := &Spinner{msg: msg}
+	if !IsTerminal(os.Stderr) {
+		return s
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			s.mu.Lock()
+			fmt.Fprintf(os.Stderr, "\r\033[K%s %s", spinnerFrames[i%len(spinnerFrames)], s.msg)
+			s.mu.Unlock()
+			select {
+			case <-s.stop:
+				fmt.Fprint(os.Stderr, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Update replaces the message shown by the spinner
+func (s *Spinner) Update(msg string) {
+	s.mu.Lock()
+	s.msg = msg
+	s.mu.Unlock()
+}
+
+// Stop removes the spinner from the terminal
+func (s *Spinner) Stop() {
+	if s.stop == nil {
+		return
+	}
+	close(s.stop)
+	<-s.done
+	s.stop = nil
+}
+
+// IsTerminal reports whether f is an interactive terminal
+func IsTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
@@ -1,6 +1,7 @@
 package token
 
 import (
+	"errors"
 	"github.com/charmbracelet/huh"
 	"log"
 )
@@ -22,3 +23,30 @@ func RequestToken() (token string, err error) {
 
 	return
 }
+
+// RequestPassphrase asks for the passphrase protecting the encrypted token file.
+// With confirm, the passphrase has to be entered twice.
+func RequestPassphrase(confirm bool) (passphrase string, err error) {
+	fields := []huh.Field{
+		huh.NewInput().
+			Title("Passphrase").
+			EchoMode(huh.EchoModePassword).
+			Value(&passphrase),
+	}
+	if confirm {
+		var repeated string
+		fields = append(fields, huh.NewInput().
+			Title("Repeat passphrase").
+			EchoMode(huh.EchoModePassword).
+			Value(&repeated).
+			Validate(func(s string) error {
+				if s != passphrase {
+					return errors.New("passphrases do not match")
+				}
+				return nil
+			}))
+	}
+
+	err = huh.NewForm(huh.NewGroup(fields...)).Run()
+	return
+}
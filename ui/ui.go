@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+	"os"
 	"strings"
 )
 
@@ -28,58 +30,96 @@ func StyleTable(t table.Model) table.Model {
 
 type TableColumn[T any] struct {
 	Title string
-	Width int
 	Value func(T) string
+	// Raw is the exact value, which --sort-by compares. Value is used if it is nil.
+	Raw func(T) string
+	// Wide columns are left out of tables unless -o wide is used
+	Wide bool
+	// Style colors the value in tables and forms
+	Style func(value string) lipgloss.Style
+	// Table is a list within the item, shown as a table of its own in the key: value form
+	Table func(T) Output
 }
 
-func Column[T any, V any](title string, width int, extract func(T) V) TableColumn[T] {
-	return TableColumn[T]{
+// Column shows the value of extract, rendered by its type, see Render
+func Column[T any, V any](title string, extract func(T) V) TableColumn[T] {
+	c := TableColumn[T]{
 		Title: title,
-		Width: width,
 		Value: func(t T) string {
+			return Render(extract(t))
+		},
+		Raw: func(t T) string {
 			return fmt.Sprint(extract(t))
 		},
 	}
+	var zero V
+	if _, ok := any(zero).(Status); ok {
+		c.Style = statusStyle
+	}
+	return c
 }
 
-func RenderTable[T any](items []T, cols ...TableColumn[T]) error {
-	tblCols := make([]table.Column, len(cols))
-	for i, c := range cols {
-		tblCols[i] = table.Column{Title: c.Title, Width: c.Width}
-	}
+// WideColumn is a Column only shown in -o wide tables
+func WideColumn[T any, V any](title string, extract func(T) V) TableColumn[T] {
+	c := Column(title, extract)
+	c.Wide = true
+	return c
+}
 
-	tblRows := make([]table.Row, len(items))
-	for i, item := range items {
-		row := make(table.Row, len(cols))
-		for j, c := range cols {
-			row[j] = c.Value(item)
-		}
-		tblRows[i] = row
+// NestedColumn shows a list within an item, such as the plans of a product: as summary
+// in tables, and as a table with cols in the key: value form of a single item.
+// With Raw it is the JSON of the list.
+func NestedColumn[T any, E any](title string, extract func(T) []E, summary func([]E) string, cols ...TableColumn[E]) TableColumn[T] {
+	return TableColumn[T]{
+		Title: title,
+		Value: func(t T) string {
+			if Raw {
+				return rawJSON(extract(t))
+			}
+			return summary(extract(t))
+		},
+		Raw: func(t T) string {
+			return rawJSON(extract(t))
+		},
+		Table: func(t T) Output {
+			return List(extract(t), cols...)
+		},
 	}
+}
 
-	t := table.New(
-		table.WithColumns(tblCols),
-		table.WithRows(tblRows),
-	)
-
-	t.SetStyles(table.Styles{
-		Header:   table.Styles{}.Header,
-		Cell:     table.Styles{}.Cell,
-		Selected: table.Styles{}.Cell,
-	})
-
-	out := strings.TrimSpace(t.View())
-	_, err := fmt.Println(out)
-	return err
+func RenderTable[T any](items []T, cols ...TableColumn[T]) error {
+	return Format(os.Stdout, "table", List(items, cols...))
 }
 
 func RenderForm[T any](item T, cols ...TableColumn[T]) error {
-	for _, col := range cols {
-		val := col.Value(item)
-		_, err := fmt.Printf("%s: %v\n", col.Title, val)
-		if err != nil {
-			return err
+	return Format(os.Stdout, "table", Item(item, cols...))
+}
+
+func renderTable(cols []OutputColumn, widths []int, rows [][]string) string {
+	lines := make([]string, 0, len(rows)+1)
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = tableCell(c.Title, widths[i], lipgloss.NewStyle())
+	}
+	lines = append(lines, strings.TrimRight(strings.Join(header, ""), " "))
+
+	for _, r := range rows {
+		cells := make([]string, len(cols))
+		for i, c := range cols {
+			style := lipgloss.NewStyle()
+			if c.Style != nil {
+				style = c.Style(r[i])
+			}
+			cells[i] = tableCell(r[i], widths[i], style)
 		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, ""), " "))
 	}
-	return nil
+	return strings.Join(lines, "\n")
+}
+
+// tableCell truncates value to the width of its column, less the padding, and pads it
+// after styling, so colors do not count towards the width
+func tableCell(value string, width int, style lipgloss.Style) string {
+	value = runewidth.Truncate(value, width-columnPadding, "…")
+	return style.Render(value) + strings.Repeat(" ", max(width-runewidth.StringWidth(value), 0))
 }
@@ -0,0 +1,74 @@
+package vps
+
+import (
+	"fmt"
+	"github.com/charmbracelet/huh"
+	"github.com/edvin/oh/api"
+	"strings"
+)
+
+// maxListedServers caps how many servers a confirmation lists by name
+const maxListedServers = 10
+
+// ConfirmAction asks whether the action should be run on the servers
+func ConfirmAction(servers []api.CloudServer, action string) (confirmed bool, err error) {
+	title := fmt.Sprintf("%s %d servers?", action, len(servers))
+	if len(servers) == 1 {
+		title = fmt.Sprintf("%s %s?", action, serverLabel(servers[0]))
+	}
+
+	err = huh.NewForm(
+		huh.NewGroup(
+			huh.NewConfirm().
+				Title(title).
+				Description(describeServers(servers)).
+				Affirmative("Yes").
+				Negative("No").
+				Value(&confirmed),
+		),
+	).Run()
+	return
+}
+
+// ConfirmByName asks for the name of the server to be typed back, for actions that
+// cannot be undone. It reports whether the name matched.
+func ConfirmByName(server api.CloudServer, action string) (bool, error) {
+	var typed string
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(fmt.Sprintf("%s %s? This cannot be undone.", action, serverLabel(server))).
+				Description(describeServers([]api.CloudServer{server}) + "\n\nType the server name to confirm").
+				Value(&typed),
+		),
+	).Run()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(typed) == server.Name, nil
+}
+
+func serverLabel(s api.CloudServer) string {
+	if s.Name == "" {
+		return fmt.Sprintf("server %d", s.Id)
+	}
+	return fmt.Sprintf("%s (%d)", s.Name, s.Id)
+}
+
+func describeServers(servers []api.CloudServer) string {
+	var lines []string
+	for i, s := range servers {
+		if i == maxListedServers {
+			lines = append(lines, fmt.Sprintf("… and %d more", len(servers)-i))
+			break
+		}
+		ips := []string{}
+		for _, ip := range []string{s.IPv4, s.IPv6} {
+			if ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s  %s", serverLabel(s), strings.Join(ips, ", "), s.Status))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,414 @@
+package vps
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/charmbracelet/huh"
+	"github.com/edvin/oh/api"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OrderChoices holds what the order wizard offers to pick from
+type OrderChoices struct {
+	Products []api.Product
+	Images   []api.CloudServerImage
+	// Zones are suggested for the availability zone, other values can be typed
+	Zones    []string
+	Networks []api.VirtualNetwork
+	// SuggestIPv4 returns a free address in the network, or "" if none is known
+	SuggestIPv4 func(networkId string) string
+	// SshKeys maps the path of a public key file to its content
+	SshKeys map[string]string
+}
+
+// OrderSummary is what the wizard asks after showing the finished order
+type OrderSummary struct {
+	Submit bool
+	// SavePath is where the order JSON should be saved, empty to not save it
+	SavePath string
+}
+
+const (
+	passwordGenerate = "generate"
+	passwordEnter    = "enter"
+
+	generatedPasswordLength = 20
+	passwordAlphabet        = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789-_.:+"
+)
+
+// OrderWizard walks through a new order step by step
+func OrderWizard(choices OrderChoices) (api.CloudServerOrder, error) {
+	var order api.CloudServerOrder
+	if len(choices.Products) == 0 {
+		return order, errors.New("no products are available to order")
+	}
+	if len(choices.Images) == 0 {
+		return order, errors.New("no images are available to order")
+	}
+
+	var distro, storage string
+	server := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Server name").
+				Value(&order.Name).
+				Validate(required("a name")),
+			huh.NewSelect[int]().
+				Title("Product").
+				Options(productOptions(choices.Products)...).
+				Value(&order.ProductId),
+			huh.NewSelect[int]().
+				Title("Plan").
+				OptionsFunc(func() []huh.Option[int] {
+					return planOptions(choices.Products, order.ProductId)
+				}, &order.ProductId).
+				Value(&order.ProductPlanId),
+		),
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Distribution").
+				Options(distroOptions(choices.Images)...).
+				Value(&distro),
+			huh.NewSelect[int]().
+				Title("Image").
+				OptionsFunc(func() []huh.Option[int] {
+					return imageOptions(choices.Images, distro)
+				}, &distro).
+				Value(&order.ImageId),
+		),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Availability zone").
+				Description(zoneDescription(choices.Zones)).
+				Suggestions(choices.Zones).
+				Value(&order.AvailabilityZone).
+				Validate(required("an availability zone")),
+			huh.NewInput().
+				Title("Storage size (GB)").
+				DescriptionFunc(func() string {
+					if min := imageMinDisk(choices.Images, order.ImageId); min > 0 {
+						return fmt.Sprintf("The image needs at least %d GB", min)
+					}
+					return ""
+				}, &order.ImageId).
+				Value(&storage).
+				Validate(func(s string) error {
+					size, err := strconv.Atoi(strings.TrimSpace(s))
+					if err != nil || size <= 0 {
+						return errors.New("enter the size in GB as a whole number")
+					}
+					if min := imageMinDisk(choices.Images, order.ImageId); size < min {
+						return fmt.Errorf("the image needs at least %d GB", min)
+					}
+					return nil
+				}),
+		),
+	)
+	if err := server.Run(); err != nil {
+		return order, err
+	}
+	order.StorageSize = strings.TrimSpace(storage)
+
+	networks, err := pickNetworks(choices)
+	if err != nil {
+		return order, err
+	}
+	order.Networks = networks
+
+	if order.Password, err = pickPassword(); err != nil {
+		return order, err
+	}
+	if order.SshKey, err = pickSshKey(choices.SshKeys); err != nil {
+		return order, err
+	}
+	return order, nil
+}
+
+// ConfirmOrder shows the finished order and asks whether to submit and where to save it
+func ConfirmOrder(order api.CloudServerOrder, choices OrderChoices) (summary OrderSummary, err error) {
+	summary.Submit = true
+	err = huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title("Order summary").
+				Description(describeOrder(order, choices)),
+			huh.NewInput().
+				Title("Save the order as JSON").
+				Description("Path of the file, leave empty to not save it. The file includes the password.").
+				Value(&summary.SavePath),
+			huh.NewConfirm().
+				Title("Submit the order now?").
+				Affirmative("Submit").
+				Negative("Don't submit").
+				Value(&summary.Submit),
+		),
+	).Run()
+	summary.SavePath = strings.TrimSpace(summary.SavePath)
+	return
+}
+
+func pickNetworks(choices OrderChoices) ([]api.VMNetwork, error) {
+	if len(choices.Networks) == 0 {
+		return nil, nil
+	}
+
+	var ids []string
+	options := make([]huh.Option[string], len(choices.Networks))
+	for i, n := range choices.Networks {
+		options[i] = huh.NewOption(fmt.Sprintf("%s (%s)", n.Name, n.Id), n.Id)
+	}
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title("Networks").
+				Description("Select the private networks to attach, or none").
+				Options(options...).
+				Value(&ids),
+		),
+	).Run()
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	networks := make([]api.VMNetwork, len(ids))
+	var fields []huh.Field
+	for i, id := range ids {
+		networks[i].Network = id
+		if choices.SuggestIPv4 != nil {
+			networks[i].FixedIPv4 = choices.SuggestIPv4(id)
+		}
+		fields = append(fields, huh.NewInput().
+			Title(fmt.Sprintf("Fixed IPv4 in %s", networkName(choices.Networks, id))).
+			Description("Prefilled with a free address, leave empty to have one assigned").
+			Value(&networks[i].FixedIPv4))
+	}
+	if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+		return nil, err
+	}
+	for i := range networks {
+		networks[i].FixedIPv4 = strings.TrimSpace(networks[i].FixedIPv4)
+	}
+	return networks, nil
+}
+
+func pickPassword() (string, error) {
+	mode := passwordGenerate
+	var password, repeated string
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Root password").
+				Options(
+					huh.NewOption("Generate a random password", passwordGenerate),
+					huh.NewOption("Enter a password", passwordEnter),
+				).
+				Value(&mode),
+		),
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Password").
+				EchoMode(huh.EchoModePassword).
+				Value(&password).
+				Validate(required("a password")),
+			huh.NewInput().
+				Title("Repeat password").
+				EchoMode(huh.EchoModePassword).
+				Value(&repeated).
+				Validate(func(s string) error {
+					if s != password {
+						return errors.New("passwords do not match")
+					}
+					return nil
+				}),
+		).WithHideFunc(func() bool { return mode != passwordEnter }),
+	).Run()
+	if err != nil {
+		return "", err
+	}
+	if mode == passwordGenerate {
+		return GeneratePassword(generatedPasswordLength)
+	}
+	return password, nil
+}
+
+func pickSshKey(keys map[string]string) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+
+	paths := make([]string, 0, len(keys))
+	for p := range keys {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	options := []huh.Option[string]{huh.NewOption("No SSH key", "")}
+	for _, p := range paths {
+		options = append(options, huh.NewOption(filepath.Base(p), keys[p]))
+	}
+
+	key := keys[paths[0]]
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("SSH public key").
+				Options(options...).
+				Value(&key),
+		),
+	).Run()
+	return key, err
+}
+
+// GeneratePassword returns a random password of the given length
+func GeneratePassword(length int) (string, error) {
+	b := make([]byte, length)
+	max := big.NewInt(int64(len(passwordAlphabet)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate a password: %w", err)
+		}
+		b[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func describeOrder(order api.CloudServerOrder, choices OrderChoices) string {
+	product, plan := "?", "?"
+	for _, p := range choices.Products {
+		if p.Id != order.ProductId {
+			continue
+		}
+		product = p.Name
+		for _, pl := range p.Plans {
+			if pl.Id == order.ProductPlanId {
+				plan = fmt.Sprintf("%s (%.2f)", pl.Name, pl.Price)
+			}
+		}
+	}
+	image := strconv.Itoa(order.ImageId)
+	for _, i := range choices.Images {
+		if i.Id == order.ImageId {
+			image = i.Name
+		}
+	}
+
+	lines := []string{
+		"Name:              " + order.Name,
+		"Product:           " + product,
+		"Plan:              " + plan,
+		"Image:             " + image,
+		"Availability zone: " + order.AvailabilityZone,
+		"Storage:           " + order.StorageSize + " GB",
+		"Password:          " + order.Password,
+	}
+	if order.SshKey != "" {
+		lines = append(lines, "SSH key:           "+keyComment(order.SshKey))
+	}
+	for _, n := range order.Networks {
+		ip := n.FixedIPv4
+		if ip == "" {
+			ip = "assigned automatically"
+		}
+		lines = append(lines, fmt.Sprintf("Network:           %s, %s", networkName(choices.Networks, n.Network), ip))
+	}
+	if order.UserData != "" {
+		lines = append(lines, fmt.Sprintf("User-data:         %d bytes of cloud-init", len(order.UserData)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func productOptions(products []api.Product) []huh.Option[int] {
+	options := make([]huh.Option[int], len(products))
+	for i, p := range products {
+		options[i] = huh.NewOption(p.Name, p.Id)
+	}
+	return options
+}
+
+func planOptions(products []api.Product, productId int) []huh.Option[int] {
+	var options []huh.Option[int]
+	for _, p := range products {
+		if p.Id != productId {
+			continue
+		}
+		for _, plan := range p.Plans {
+			options = append(options, huh.NewOption(fmt.Sprintf("%s – %.2f", plan.Name, plan.Price), plan.Id))
+		}
+	}
+	return options
+}
+
+func distroOptions(images []api.CloudServerImage) []huh.Option[string] {
+	seen := map[string]bool{}
+	var distros []string
+	for _, i := range images {
+		if !seen[i.OSDistro] {
+			seen[i.OSDistro] = true
+			distros = append(distros, i.OSDistro)
+		}
+	}
+	sort.Strings(distros)
+	return huh.NewOptions(distros...)
+}
+
+func imageOptions(images []api.CloudServerImage, distro string) []huh.Option[int] {
+	var options []huh.Option[int]
+	for _, i := range images {
+		if i.OSDistro == distro {
+			options = append(options, huh.NewOption(fmt.Sprintf("%s (%s)", i.Name, i.OSVersion), i.Id))
+		}
+	}
+	return options
+}
+
+func imageMinDisk(images []api.CloudServerImage, imageId int) int {
+	for _, i := range images {
+		if i.Id == imageId {
+			return i.MinDisk
+		}
+	}
+	return 0
+}
+
+func zoneDescription(zones []string) string {
+	if len(zones) == 0 {
+		return ""
+	}
+	return "In use by your servers: " + strings.Join(zones, ", ")
+}
+
+func networkName(networks []api.VirtualNetwork, id string) string {
+	for _, n := range networks {
+		if n.Id == id {
+			return n.Name
+		}
+	}
+	return id
+}
+
+// keyComment returns the comment of an OpenSSH public key, usually user@host
+func keyComment(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) >= 3 {
+		return strings.Join(fields[2:], " ")
+	}
+	if len(fields) == 2 {
+		return fields[0]
+	}
+	return key
+}
+
+func required(what string) func(string) error {
+	return func(s string) error {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("please enter %s", what)
+		}
+		return nil
+	}
+}